@@ -0,0 +1,220 @@
+/*
+ *
+ *  * Licensed to the Apache Software Foundation (ASF) under one
+ *  * or more contributor license agreements.  See the NOTICE file
+ *  * distributed with this work for additional information
+ *  * regarding copyright ownership.  The ASF licenses this file
+ *  * to you under the Apache License, Version 2.0 (the
+ *  * "License"); you may not use this file except in compliance
+ *  * with the License.  You may obtain a copy of the License at
+ *  *
+ *  *     http://www.apache.org/licenses/LICENSE-2.0
+ *  *
+ *  * Unless required by applicable law or agreed to in writing, software
+ *  * distributed under the License is distributed on an "AS IS" BASIS,
+ *  * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  * See the License for the specific language governing permissions and
+ *  * limitations under the License.
+ *
+ *
+ */
+
+package chaos
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"godzilla/env"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// alertPollInterval is the wait between the queries of the alerts a run waits for
+var alertPollInterval = 30 * time.Second
+
+var alertmanagerClient = &http.Client{Timeout: 10 * time.Second}
+
+type alert struct {
+	Labels map[string]string `json:"labels"`
+}
+
+type silenceMatcher struct {
+	Name    string `json:"name"`
+	Value   string `json:"value"`
+	IsRegex bool   `json:"isRegex"`
+	IsEqual bool   `json:"isEqual"`
+}
+
+type silence struct {
+	Matchers  []silenceMatcher `json:"matchers"`
+	StartsAt  time.Time        `json:"startsAt"`
+	EndsAt    time.Time        `json:"endsAt"`
+	CreatedBy string           `json:"createdBy"`
+	Comment   string           `json:"comment"`
+}
+
+// targetNamespaces returns the namespaces the steps inject chaos into.
+func targetNamespaces(chaosJobs [][]ChaosJob) []string {
+	set := make(map[string]bool)
+	for _, parallelJobs := range chaosJobs {
+		for _, j := range parallelJobs {
+			if ns := j.Config["APP_NAMESPACE"]; ns != "" {
+				set[ns] = true
+			}
+		}
+	}
+	var namespaces []string
+	for ns := range set {
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
+	return namespaces
+}
+
+// firingAlerts returns the names of the active alerts of ALERTMANAGER_SEVERITIES in the namespaces,
+// silenced and inhibited alerts are ignored.
+func firingAlerts(ctx context.Context, namespaces []string) ([]string, error) {
+	severities := make(map[string]bool)
+	for _, severity := range strings.Split(env.AlertmanagerSeverities, ",") {
+		severities[strings.TrimSpace(severity)] = true
+	}
+	var names []string
+	for _, ns := range namespaces {
+		query := url.Values{}
+		query.Set("active", "true")
+		query.Set("silenced", "false")
+		query.Set("inhibited", "false")
+		query.Add("filter", fmt.Sprintf("%s=%q", env.AlertmanagerNamespaceLabel, ns))
+		var alerts []alert
+		err := callAlertmanager(ctx, http.MethodGet, "/api/v2/alerts?"+query.Encode(), nil, &alerts)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range alerts {
+			if severities[a.Labels["severity"]] {
+				names = append(names, fmt.Sprintf("%s/%s", ns, a.Labels["alertname"]))
+			}
+		}
+	}
+	return names, nil
+}
+
+// alertsCheck refuses the run if critical alerts are firing for its targets, it is a no-op when no
+// alertmanager is configured or the run waits for the alerts instead.
+func alertsCheck(ctx context.Context, chaosJobs [][]ChaosJob) error {
+	if env.AlertmanagerUrl == "" || env.AlertmanagerWait != "" {
+		return nil
+	}
+	names, err := firingAlerts(ctx, targetNamespaces(chaosJobs))
+	if err != nil {
+		return fmt.Errorf("query alertmanager failed: %s", err.Error())
+	}
+	if len(names) > 0 {
+		return fmt.Errorf("alerts firing: %s", strings.Join(names, ", "))
+	}
+	return nil
+}
+
+// waitForAlerts blocks until no critical alert is firing for the targets of the run,
+// for ALERTMANAGER_WAIT at most.
+func waitForAlerts(ctx context.Context, chaosJobs [][]ChaosJob) error {
+	if env.AlertmanagerUrl == "" || env.AlertmanagerWait == "" {
+		return nil
+	}
+	wait, err := time.ParseDuration(env.AlertmanagerWait)
+	if err != nil {
+		return fmt.Errorf("parse ALERTMANAGER_WAIT failed: %s", err.Error())
+	}
+	namespaces := targetNamespaces(chaosJobs)
+	deadline := time.Now().Add(wait)
+	for {
+		names, err := firingAlerts(ctx, namespaces)
+		if err != nil {
+			return fmt.Errorf("query alertmanager failed: %s", err.Error())
+		}
+		if len(names) == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("alerts still firing after %s: %s", env.AlertmanagerWait, strings.Join(names, ", "))
+		}
+		logFrom(ctx).Infof("run waits for alerts %s", strings.Join(names, ", "))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(alertPollInterval):
+		}
+	}
+}
+
+// silenceTargets silences the alerts of the target namespaces while the run is going on,
+// the silences expire after ALERTMANAGER_SILENCE_DURATION even if godzilla fails to delete them.
+func silenceTargets(ctx context.Context, jobStatusId uint, chaosJobs [][]ChaosJob) []string {
+	if env.AlertmanagerUrl == "" || env.AlertmanagerSilenceDuration == "" || ctx.Err() != nil {
+		return nil
+	}
+	duration, err := time.ParseDuration(env.AlertmanagerSilenceDuration)
+	if err != nil {
+		logFrom(ctx).Errorf("parse ALERTMANAGER_SILENCE_DURATION failed, reason: %s", err.Error())
+		return nil
+	}
+	var ids []string
+	for _, ns := range targetNamespaces(chaosJobs) {
+		var resp struct {
+			SilenceID string `json:"silenceID"`
+		}
+		err := callAlertmanager(ctx, http.MethodPost, "/api/v2/silences", silence{
+			Matchers:  []silenceMatcher{{Name: env.AlertmanagerNamespaceLabel, Value: ns, IsEqual: true}},
+			StartsAt:  time.Now(),
+			EndsAt:    time.Now().Add(duration),
+			CreatedBy: "godzilla",
+			Comment:   fmt.Sprintf("chaos run %v of scenario %s", jobStatusId, scenarioFrom(ctx)),
+		}, &resp)
+		if err != nil {
+			logFrom(ctx).Errorf("silence namespace %s failed, reason: %s", ns, err.Error())
+			continue
+		}
+		ids = append(ids, resp.SilenceID)
+	}
+	return ids
+}
+
+func expireSilences(ctx context.Context, ids []string) {
+	ctx = context.WithoutCancel(ctx)
+	for _, id := range ids {
+		err := callAlertmanager(ctx, http.MethodDelete, "/api/v2/silence/"+url.PathEscape(id), nil, nil)
+		if err != nil {
+			logFrom(ctx).Errorf("delete silence %s failed, reason: %s", id, err.Error())
+		}
+	}
+}
+
+func callAlertmanager(ctx context.Context, method, path string, body any, out any) error {
+	reader := bytes.NewReader(nil)
+	if body != nil {
+		data, _ := json.Marshal(body)
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(env.AlertmanagerUrl, "/")+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := alertmanagerClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,189 @@
+/*
+ *
+ *  * Licensed to the Apache Software Foundation (ASF) under one
+ *  * or more contributor license agreements.  See the NOTICE file
+ *  * distributed with this work for additional information
+ *  * regarding copyright ownership.  The ASF licenses this file
+ *  * to you under the Apache License, Version 2.0 (the
+ *  * "License"); you may not use this file except in compliance
+ *  * with the License.  You may obtain a copy of the License at
+ *  *
+ *  *     http://www.apache.org/licenses/LICENSE-2.0
+ *  *
+ *  * Unless required by applicable law or agreed to in writing, software
+ *  * distributed under the License is distributed on an "AS IS" BASIS,
+ *  * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  * See the License for the specific language governing permissions and
+ *  * limitations under the License.
+ *
+ *
+ */
+package chaos
+
+import (
+	"context"
+	"encoding/json"
+	"godzilla/env"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// stubAlertmanager serves the alerts returned by firing for the namespace of the filter and records the
+// silences.
+type stubAlertmanager struct {
+	firing   func(namespace string) []alert
+	queries  atomic.Int32
+	mu       sync.Mutex
+	silences []silence
+	expired  []string
+}
+
+func (s *stubAlertmanager) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/api/v2/alerts":
+		s.queries.Add(1)
+		query := r.URL.Query()
+		if query.Get("active") != "true" || query.Get("silenced") != "false" || query.Get("inhibited") != "false" {
+			http.Error(w, "unexpected query "+r.URL.RawQuery, http.StatusBadRequest)
+			return
+		}
+		namespace := strings.TrimSuffix(strings.TrimPrefix(query.Get("filter"), `namespace="`), `"`)
+		_ = json.NewEncoder(w).Encode(s.firing(namespace))
+	case r.Method == http.MethodPost && r.URL.Path == "/api/v2/silences":
+		var body silence
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.mu.Lock()
+		s.silences = append(s.silences, body)
+		id := body.Matchers[0].Value + "-silence"
+		s.mu.Unlock()
+		_, _ = w.Write([]byte(`{"silenceID":"` + id + `"}`))
+	case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/api/v2/silence/"):
+		s.mu.Lock()
+		s.expired = append(s.expired, strings.TrimPrefix(r.URL.Path, "/api/v2/silence/"))
+		s.mu.Unlock()
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func withAlertmanager(t *testing.T, stub *stubAlertmanager, wait, silenceDuration string) {
+	t.Helper()
+	server := httptest.NewServer(stub)
+	url, waitEnv, silenceEnv := env.AlertmanagerUrl, env.AlertmanagerWait, env.AlertmanagerSilenceDuration
+	env.AlertmanagerUrl, env.AlertmanagerWait, env.AlertmanagerSilenceDuration = server.URL, wait, silenceDuration
+	t.Cleanup(func() {
+		server.Close()
+		env.AlertmanagerUrl, env.AlertmanagerWait, env.AlertmanagerSilenceDuration = url, waitEnv, silenceEnv
+	})
+}
+
+func alertJobs(namespaces ...string) [][]ChaosJob {
+	var stage []ChaosJob
+	for _, ns := range namespaces {
+		stage = append(stage, ChaosJob{Name: "step-" + ns, Config: map[string]string{"APP_NAMESPACE": ns}})
+	}
+	return [][]ChaosJob{stage}
+}
+
+func TestAlertsCheck(t *testing.T) {
+	stub := &stubAlertmanager{firing: func(namespace string) []alert {
+		if namespace != "shop" {
+			return nil
+		}
+		return []alert{
+			{Labels: map[string]string{"alertname": "HighErrorRate", "severity": "critical"}},
+			{Labels: map[string]string{"alertname": "SlowResponses", "severity": "warning"}},
+		}
+	}}
+	withAlertmanager(t, stub, "", "")
+
+	err := alertsCheck(context.Background(), alertJobs("shop", "cart"))
+	if err == nil || err.Error() != "alerts firing: shop/HighErrorRate" {
+		t.Errorf("alertsCheck() = %v, want the critical alert of shop", err)
+	}
+	err = alertsCheck(context.Background(), alertJobs("cart"))
+	if err != nil {
+		t.Errorf("alertsCheck() = %v, want nil without alerts firing", err)
+	}
+
+	env.AlertmanagerWait = "1m"
+	queries := stub.queries.Load()
+	err = alertsCheck(context.Background(), alertJobs("shop"))
+	if err != nil || stub.queries.Load() != queries {
+		t.Errorf("alertsCheck() = %v, want no check of the alerts the run waits for", err)
+	}
+}
+
+func TestWaitForAlerts(t *testing.T) {
+	interval := alertPollInterval
+	alertPollInterval = time.Millisecond
+	defer func() { alertPollInterval = interval }()
+
+	var resolved atomic.Bool
+	stub := &stubAlertmanager{firing: func(string) []alert {
+		if resolved.Load() {
+			return nil
+		}
+		return []alert{{Labels: map[string]string{"alertname": "HighErrorRate", "severity": "critical"}}}
+	}}
+	withAlertmanager(t, stub, "1h", "")
+
+	time.AfterFunc(50*time.Millisecond, func() { resolved.Store(true) })
+	err := waitForAlerts(context.Background(), alertJobs("shop"))
+	if err != nil || stub.queries.Load() < 2 {
+		t.Errorf("waitForAlerts() = %v after %d queries, want nil once the alert resolves", err, stub.queries.Load())
+	}
+
+	resolved.Store(false)
+	env.AlertmanagerWait = "20ms"
+	err = waitForAlerts(context.Background(), alertJobs("shop"))
+	if err == nil || err.Error() != "alerts still firing after 20ms: shop/HighErrorRate" {
+		t.Errorf("waitForAlerts() = %v, want the alerts still firing", err)
+	}
+
+	env.AlertmanagerWait = "1h"
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	// the run is cancelled either while it waits or while it queries the alerts
+	err = waitForAlerts(ctx, alertJobs("shop"))
+	if err == nil || !strings.Contains(err.Error(), context.DeadlineExceeded.Error()) {
+		t.Errorf("waitForAlerts() = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestSilenceTargets(t *testing.T) {
+	stub := &stubAlertmanager{firing: func(string) []alert { return nil }}
+	withAlertmanager(t, stub, "", "30m")
+
+	ctx := withScenario(context.Background(), "nginx")
+	ids := silenceTargets(ctx, 7, alertJobs("shop", "cart", "shop"))
+	if strings.Join(ids, ",") != "cart-silence,shop-silence" {
+		t.Errorf("silenceTargets() = %v, want a silence per namespace", ids)
+	}
+	for _, s := range stub.silences {
+		if len(s.Matchers) != 1 || s.Matchers[0].Name != "namespace" || !s.Matchers[0].IsEqual {
+			t.Errorf("matchers = %+v, want the namespace label", s.Matchers)
+		}
+		if s.Comment != "chaos run 7 of scenario nginx" || s.CreatedBy != "godzilla" {
+			t.Errorf("silence = %+v, want the run and scenario in the comment", s)
+		}
+		if d := s.EndsAt.Sub(s.StartsAt); d < 29*time.Minute || d > 31*time.Minute {
+			t.Errorf("silence lasts %v, want 30m", d)
+		}
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	expireSilences(cancelled, ids)
+	if strings.Join(stub.expired, ",") != "cart-silence,shop-silence" {
+		t.Errorf("expired silences = %v, want %v even after the run is cancelled", stub.expired, ids)
+	}
+}
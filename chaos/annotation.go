@@ -0,0 +1,137 @@
+/*
+ *
+ *  * Licensed to the Apache Software Foundation (ASF) under one
+ *  * or more contributor license agreements.  See the NOTICE file
+ *  * distributed with this work for additional information
+ *  * regarding copyright ownership.  The ASF licenses this file
+ *  * to you under the Apache License, Version 2.0 (the
+ *  * "License"); you may not use this file except in compliance
+ *  * with the License.  You may obtain a copy of the License at
+ *  *
+ *  *     http://www.apache.org/licenses/LICENSE-2.0
+ *  *
+ *  * Unless required by applicable law or agreed to in writing, software
+ *  * distributed under the License is distributed on an "AS IS" BASIS,
+ *  * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  * See the License for the specific language governing permissions and
+ *  * limitations under the License.
+ *
+ *
+ */
+
+package chaos
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"gopkg.in/yaml.v2"
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"strconv"
+)
+
+const (
+	ChaosRunLabel           = "godzilla.io/chaos-run"
+	ChaosTypeLabel          = "godzilla.io/chaos-type"
+	ChaosScenarioAnnotation = "godzilla.io/chaos-scenario"
+	ChaosStepAnnotation     = "godzilla.io/chaos-step"
+)
+
+// annotatePod marks the pod as a chaos target, the marks are put as labels and annotations
+// so that both selectors and log pipelines can pick them up.
+func (chaosJob *ChaosJob) annotatePod(ctx context.Context, jobStatusId uint, pod *coreV1.Pod) error {
+	marks := map[string]any{
+		ChaosRunLabel:  fmt.Sprintf("%v", jobStatusId),
+		ChaosTypeLabel: chaosJob.Type,
+	}
+	annotations := map[string]any{
+		ChaosRunLabel:           fmt.Sprintf("%v", jobStatusId),
+		ChaosTypeLabel:          chaosJob.Type,
+		ChaosScenarioAnnotation: scenarioFrom(ctx),
+		ChaosStepAnnotation:     chaosJob.Name,
+	}
+	return patchPodMarks(ctx, pod, marks, annotations)
+}
+
+// unannotatePod removes the marks put by annotatePod.
+func unannotatePod(ctx context.Context, pod *coreV1.Pod) error {
+	marks := map[string]any{
+		ChaosRunLabel:  nil,
+		ChaosTypeLabel: nil,
+	}
+	annotations := map[string]any{
+		ChaosRunLabel:           nil,
+		ChaosTypeLabel:          nil,
+		ChaosScenarioAnnotation: nil,
+		ChaosStepAnnotation:     nil,
+	}
+	return patchPodMarks(ctx, pod, marks, annotations)
+}
+
+func patchPodMarks(ctx context.Context, pod *coreV1.Pod, labels, annotations map[string]any) error {
+	patch, err := json.Marshal(map[string]any{
+		"metadata": map[string]any{
+			"labels":      labels,
+			"annotations": annotations,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	kubeCtx, done := kubeCall(ctx, "patch_pod", attribute.String("pod", pod.Name))
+	_, err = executorFrom(ctx).client.CoreV1().Pods(pod.Namespace).Patch(kubeCtx, pod.Name, types.MergePatchType, patch, metaV1.PatchOptions{})
+	done(err)
+	return err
+}
+
+// SweepTargets removes the marks left on pods by the finished runs of the store, e.g. after a crash skipped
+// revertTargets. The marks of runs not finished may belong to another replica, and the marks of runs not in
+// the store belong to embedded Runners, both are left alone.
+func (h *Handler) SweepTargets() {
+	h.sweepTargets(context.Background())
+}
+
+func (h *Handler) sweepTargets(ctx context.Context) {
+	kubeCtx, done := kubeCall(ctx, "list_pods")
+	pods, err := executorFrom(ctx).client.CoreV1().Pods("").List(kubeCtx, metaV1.ListOptions{
+		LabelSelector: ChaosRunLabel,
+	})
+	done(err)
+	if err != nil {
+		logrus.Errorf("list the pods marked by runs failed, reason: %s", err.Error())
+		return
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		id, err := strconv.ParseUint(pod.Labels[ChaosRunLabel], 10, 64)
+		if err != nil {
+			continue
+		}
+		if _, running := h.runCancels.Load(uint(id)); running {
+			continue
+		}
+		run, err := h.runs.GetById(uint(id))
+		if err != nil {
+			runLog(uint(id)).Errorf("get run of the marked pod %s failed, reason: %s", pod.Name, err.Error())
+			continue
+		}
+		if run.Id == 0 {
+			continue
+		}
+		var chaosJobs [][]ChaosJob
+		err = yaml.Unmarshal([]byte(run.Status), &chaosJobs)
+		if err != nil || !stepsFinished(chaosJobs) {
+			continue
+		}
+		err = unannotatePod(ctx, pod)
+		if err != nil {
+			runLog(run.Id).WithField(TargetPodField, pod.Name).Warnf("remove leftover marks failed, reason: %s", err.Error())
+			continue
+		}
+		runLog(run.Id).WithField(TargetPodField, pod.Name).Info("leftover marks removed")
+	}
+}
@@ -23,40 +23,77 @@ package chaos
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"godzilla/chaos/litmus/pod"
 	"godzilla/db"
-	"godzilla/env"
+	"godzilla/storage"
 	"godzilla/types"
 	"gopkg.in/yaml.v3"
 	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"math"
 	"net/http"
+	"strconv"
 	"sync"
+	"time"
 )
 
-type ChaosJob struct {
-	Name               string            `yaml:"name" binding:"required"`
-	Type               string            `yaml:"type" binding:"required"`
-	Config             map[string]string `yaml:"config"`
-	Image              string            `yaml:"image"`
-	ServiceAccountName string            `yaml:"serviceAccountName"`
-	Status             JobStatus         `yaml:"status"`
-	FailedReason       string            `yaml:"failedReason"`
+// Handler serves the chaos api and tracks the status of the runs it starts.
+type Handler struct {
+	scenarios     db.ScenarioStore
+	runs          db.RunStore
+	events        db.EventStore
+	webhooks      db.WebhookStore
+	notifications db.NotificationStore
+	// logs keeps the logs of the chaos job pods
+	logs storage.Store
+	// artifacts keeps the evidence of the runs
+	artifacts storage.Store
+	// payloads are the events waiting for the webhook worker
+	payloads chan WebhookPayload
+	// deliveries is the queue of the webhook worker
+	deliveries chan webhookDelivery
+	// runScenarios maps the id of the unfinished runs to their scenario name
+	runScenarios sync.Map
+	// runCancels maps the id of the runs started by this instance to the func aborting them
+	runCancels sync.Map
+	// watchers are the streams of the runs waiting for their status changes
+	watchers runWatchers
 }
 
-func (chaosJob *ChaosJob) Run(jobStatusId uint) {
+func NewHandler(store db.Store, logs, artifacts storage.Store) *Handler {
+	return &Handler{
+		scenarios:     store.Scenarios,
+		runs:          store.Runs,
+		events:        store.Events,
+		webhooks:      store.Webhooks,
+		notifications: store.Notifications,
+		logs:          logs,
+		artifacts:     artifacts,
+		payloads:      make(chan WebhookPayload, webhookQueueSize),
+		deliveries:    make(chan webhookDelivery, webhookQueueSize),
+	}
+}
+
+// ChaosJob is a step of a scenario, the wire type with the methods running it.
+type ChaosJob types.ChaosJob
+
+func (chaosJob *ChaosJob) Run(ctx context.Context, jobStatusId uint) {
 	switch chaosJob.Type {
 	case string(types.LitmusPodDelete):
-		runLitmusCommon(chaosJob, jobStatusId)
+		runLitmusCommon(ctx, chaosJob, jobStatusId)
 	case string(types.LitmusPodIoStress):
-		runLitmusStress(chaosJob, jobStatusId)
+		runLitmusStress(ctx, chaosJob, jobStatusId)
 	}
 }
 
+// preCheck validates the steps, the returned *ValidationError lists every step and field which is not valid.
 func preCheck(chaosJobs [][]ChaosJob) error {
+	var validationErr ValidationError
 	dup := make(map[string]string)
 	for _, parallelJobs := range chaosJobs {
 		for _, j := range parallelJobs {
@@ -64,43 +101,71 @@ func preCheck(chaosJobs [][]ChaosJob) error {
 			if !ok {
 				dup[j.Name] = ""
 			} else {
-				return errors.New(fmt.Sprintf("duplicate step name found: %s", j.Name))
+				validationErr.add(j.Name, "name", "duplicate step name found: %s", j.Name)
 			}
 			switch j.Type {
 			case string(types.LitmusPodDelete), string(types.LitmusPodIoStress):
 			default:
-				return errors.New(fmt.Sprintf("unsupported type %s", j.Type))
+				validationErr.add(j.Name, "type", "unsupported type %s", j.Type)
 			}
+			// the jobs slice the target pods by the percentage, out of range it would panic
+			checkIntConfig(&validationErr, j, "PODS_AFFECTED_PERC", 0, 100)
+			checkIntConfig(&validationErr, j, "TOTAL_CHAOS_DURATION", 0, math.MaxInt32)
+			checkIntConfig(&validationErr, j, "TERMINATION_GRACE_PERIOD_SECONDS", 0, math.MaxInt32)
 		}
 	}
-	return nil
+	return validationErr.err()
+}
+
+// checkIntConfig records a config.KEY field error if the value of the key is set and not an integer
+// between min and max.
+func checkIntConfig(validationErr *ValidationError, j ChaosJob, key string, min, max int) {
+	v := j.Config[key]
+	if v == "" {
+		return
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < min || n > max {
+		validationErr.add(j.Name, "config."+key, "%s of step %s must be an integer between %d and %d, got %s",
+			key, j.Name, min, max, v)
+	}
 }
 
-func (chaosJob *ChaosJob) cleanJob(jobStatusId uint) error {
-	logrus.Infof("cleaning up the chaos job, status id: %v", jobStatusId)
+func (chaosJob *ChaosJob) cleanJob(ctx context.Context, jobStatusId uint) error {
+	logFrom(ctx).Info("cleaning up the chaos jobs")
 	policy := metaV1.DeletePropagationForeground
+	kube := executorFrom(ctx)
 	// get name
-	jobList, err := client.BatchV1().Jobs(env.JobNamespace).List(context.TODO(), metaV1.ListOptions{
+	kubeCtx, done := kubeCall(ctx, "list_jobs")
+	jobList, err := kube.client.BatchV1().Jobs(kube.namespace).List(kubeCtx, metaV1.ListOptions{
 		LabelSelector: fmt.Sprintf("chaos.job.id=%v", jobStatusId),
 	})
+	done(err)
 	if err != nil {
 		return err
 	}
 	for _, j := range jobList.Items {
-		err := client.BatchV1().Jobs(env.JobNamespace).Delete(context.TODO(), j.Name, metaV1.DeleteOptions{
+		// a job still being deleted in the foreground was already taken off the gauge
+		if j.DeletionTimestamp != nil {
+			continue
+		}
+		kubeCtx, done := kubeCall(ctx, "delete_job", attribute.String("job", j.Name))
+		err := kube.client.BatchV1().Jobs(kube.namespace).Delete(kubeCtx, j.Name, metaV1.DeleteOptions{
 			PropagationPolicy: &policy,
 		})
+		done(err)
 		if err != nil {
 			return err
 		}
+		// the jobs of the whole run are listed, so the gauge series is the one of the job and not of the step
+		chaosJobsActive.WithLabelValues(j.Labels["chaos.job.type"], j.Labels["chaos.job.namespace"]).Dec()
 	}
 	return nil
 }
 
-type ChaosBody struct {
-	Scenario         string            `json:"scenario" binding:"required"`
-	OverriddenConfig map[string]string `json:"overriddenConfig,omitempty"`
-}
+type ChaosBody = types.ChaosBody
+
+const triggeredByHeader = "X-Triggered-By"
 
 func overrideConfig(chaosJobs [][]ChaosJob, body ChaosBody) {
 	for i := range chaosJobs {
@@ -155,75 +220,107 @@ func overrideConfigOne(chaosJobs [][]ChaosJob) {
 	}
 }
 
-func CreateChaos(c *gin.Context) {
-	var body ChaosBody
-	err := c.BindJSON(&body)
+// CreateChaos starts a run of a stored scenario, the /api/v1 route has the scenario in the path.
+func (h *Handler) CreateChaos(c *gin.Context) {
+	body := ChaosBody{Scenario: c.Param("name")}
+	// the body is optional on the /api/v1 route
+	if body.Scenario == "" || c.Request.ContentLength != 0 {
+		if !bindJSON(c, &body) {
+			return
+		}
+	}
+	if name := c.Param("name"); name != "" {
+		body.Scenario = name
+	}
+	wait, err := parseWait(c, "wait", 0)
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse(RequestError, err))
 		return
 	}
 
 	// run all inside scenarios
-	var (
-		chaosJobs [][]ChaosJob
-		wg        sync.WaitGroup
-	)
-	logrus.Infof("getting scenario deifition for %s", body.Scenario)
-	s := db.Scenario{Name: body.Scenario}
-	err = s.GetByName()
+	var chaosJobs [][]ChaosJob
+	ctx, runSpan := startRunSpan(c, attribute.String("scenario", body.Scenario))
+	ctx = withLogFields(ctx, logrus.Fields{ScenarioField: body.Scenario})
+	logFrom(ctx).Info("getting scenario definition")
+	_, span := tracer.Start(ctx, "scenario.load")
+	s, err := h.scenarios.GetByName(body.Scenario)
 	if err != nil {
+		endSpan(span, err)
+		endSpan(runSpan, err)
 		c.AbortWithStatusJSON(http.StatusNotFound, ErrorResponse(ReadFileError, err))
 		return
 	}
+	if s.Id == 0 {
+		endSpan(span, nil)
+		endSpan(runSpan, nil)
+		c.AbortWithStatusJSON(http.StatusNotFound, ErrorResponse(ScenarioNotFound, nil))
+		return
+	}
 
-	logrus.Infof("running scenario: %s", body.Scenario)
+	logFrom(ctx).Info("running scenario")
 	data := s.Definition
 	err = yaml.Unmarshal([]byte(data), &chaosJobs)
+	endSpan(span, err)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse(YamlMarshalError, err))
+		endSpan(runSpan, err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse(YamlUnmarshalError, err))
 		return
 	}
 	// override the configuration
-	logrus.Infof("override the configuration for %s", body.Scenario)
+	logFrom(ctx).Info("override the configuration")
+	_, span = tracer.Start(ctx, "overrideConfig")
 	overrideConfig(chaosJobs, body)
+	span.End()
 
 	// pre-check before run
-	logrus.Infof("precheck for %s", body.Scenario)
+	logFrom(ctx).Info("precheck")
+	_, span = tracer.Start(ctx, "preCheck")
 	err = preCheck(chaosJobs)
+	endSpan(span, err)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError,
-			ErrorResponse(InvalidScenario, err))
+		endSpan(runSpan, err)
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, ErrorResponse(InvalidScenario, err))
 		return
 	}
 
-	jobStatusId, err := initStatus(chaosJobs, s.Id)
+	_, span = tracer.Start(ctx, "alertsCheck")
+	err = alertsCheck(ctx, chaosJobs)
+	endSpan(span, err)
 	if err != nil {
+		endSpan(runSpan, err)
+		c.AbortWithStatusJSON(http.StatusConflict, ErrorResponse(AlertsFiring, err, err.Error()))
+		return
+	}
+
+	traceId := traceIdOf(runSpan)
+	if body.TriggeredBy == "" {
+		body.TriggeredBy = c.GetHeader(triggeredByHeader)
+	}
+	jobStatusId, err := h.initStatus(chaosJobs, s.Id, traceId, body.TriggeredBy)
+	if err != nil {
+		endSpan(runSpan, err)
 		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse(MySqlSaveError, err))
 		return
 	}
-	logrus.Infof("scenario %s is ready now, current run id is %v", body.Scenario, jobStatusId)
+	ctx = withLogFields(ctx, logrus.Fields{RunIdField: jobStatusId})
+	logFrom(ctx).Info("scenario is ready now")
+	h.runScenarios.Store(jobStatusId, body.Scenario)
+	runsStarted.WithLabelValues(body.Scenario).Inc()
+	h.notify(WebhookPayload{Event: RunStartedEvent, RunId: jobStatusId, Scenario: body.Scenario, Timestamp: time.Now()})
+	h.notifyChat(ChatMessage{Event: RunStartedEvent, RunId: jobStatusId, Scenario: body.Scenario, Stages: chaosJobs})
 
-	go func() {
-		for _, parallelJobs := range chaosJobs {
-			for _, j := range parallelJobs {
-				wg.Add(1)
-				j := j
-				go func() {
-					logrus.Infof("running scenario %s, id: %v, job %s", body.Scenario, jobStatusId, j.Name)
-					j.Run(jobStatusId)
-					wg.Done()
-				}()
-			}
-			wg.Wait()
-		}
-	}()
-	c.JSON(http.StatusCreated, NormalResponse(Ok, jobStatusId))
+	runSpan.SetAttributes(attribute.Int("run.id", int(jobStatusId)))
+	go h.runStages(withScenario(ctx, body.Scenario), runSpan, chaosJobs, jobStatusId)
+	if traceId != "" {
+		c.Header(traceIdHeader, traceId)
+	}
+	h.respondCreated(c, jobStatusId, wait)
 }
 
-func GetChaos(c *gin.Context) {
+func (h *Handler) GetChaos(c *gin.Context) {
 	scenario := c.Query("scenario")
-	s := db.Scenario{Name: scenario}
-	err := s.GetByName()
+	s, err := h.scenarios.GetByName(scenario)
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse(MySqlError, err))
 		return
@@ -235,48 +332,173 @@ func GetChaos(c *gin.Context) {
 	c.JSON(http.StatusOK, NormalResponse(Ok, s.Definition))
 }
 
-func CreateChaosOne(c *gin.Context) {
+func (h *Handler) CreateChaosOne(c *gin.Context) {
 	// run all inside scenarios
-	var (
-		chaosJobs [][]ChaosJob
-		wg        sync.WaitGroup
-	)
-	err := c.BindJSON(&chaosJobs)
+	var chaosJobs [][]ChaosJob
+	if !bindJSON(c, &chaosJobs) {
+		return
+	}
+	wait, err := parseWait(c, "wait", 0)
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse(RequestError, err))
 		return
 	}
 
+	ctx, runSpan := startRunSpan(c, attribute.String("scenario", adHocScenario))
+	ctx = withLogFields(ctx, logrus.Fields{ScenarioField: adHocScenario})
+	_, span := tracer.Start(ctx, "overrideConfig")
 	overrideConfigOne(chaosJobs)
+	span.End()
 
 	// pre-check before run
+	_, span = tracer.Start(ctx, "preCheck")
 	err = preCheck(chaosJobs)
+	endSpan(span, err)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError,
-			ErrorResponse(InvalidScenario, err))
+		endSpan(runSpan, err)
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, ErrorResponse(InvalidScenario, err))
 		return
 	}
 
-	jobStatusId, err := initStatusOne(chaosJobs)
+	_, span = tracer.Start(ctx, "alertsCheck")
+	err = alertsCheck(ctx, chaosJobs)
+	endSpan(span, err)
 	if err != nil {
+		endSpan(runSpan, err)
+		c.AbortWithStatusJSON(http.StatusConflict, ErrorResponse(AlertsFiring, err, err.Error()))
+		return
+	}
+
+	traceId := traceIdOf(runSpan)
+	jobStatusId, err := h.initStatusOne(chaosJobs, traceId, c.GetHeader(triggeredByHeader))
+	if err != nil {
+		endSpan(runSpan, err)
 		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse(MySqlSaveError, err))
 		return
 	}
-	logrus.Infof("current run id is %v", jobStatusId)
+	ctx = withLogFields(ctx, logrus.Fields{RunIdField: jobStatusId})
+	logFrom(ctx).Info("run is ready now")
+	h.runScenarios.Store(jobStatusId, adHocScenario)
+	runsStarted.WithLabelValues(adHocScenario).Inc()
+	h.notify(WebhookPayload{Event: RunStartedEvent, RunId: jobStatusId, Scenario: adHocScenario, Timestamp: time.Now()})
+	h.notifyChat(ChatMessage{Event: RunStartedEvent, RunId: jobStatusId, Scenario: adHocScenario, Stages: chaosJobs})
+
+	runSpan.SetAttributes(attribute.Int("run.id", int(jobStatusId)))
+	go h.runStages(withScenario(ctx, adHocScenario), runSpan, chaosJobs, jobStatusId)
+	if traceId != "" {
+		c.Header(traceIdHeader, traceId)
+	}
+	h.respondCreated(c, jobStatusId, wait)
+}
 
-	go func() {
+// runStages runs the stages one after another and the steps inside a stage in parallel,
+// the run span ends with the last stage.
+func (h *Handler) runStages(ctx context.Context, runSpan trace.Span, chaosJobs [][]ChaosJob, jobStatusId uint) {
+	defer runSpan.End()
+	ctx, cancel := context.WithCancel(ctx)
+	h.runCancels.Store(jobStatusId, cancel)
+	defer func() {
+		h.runCancels.Delete(jobStatusId)
+		cancel()
+	}()
+	err := waitForAlerts(ctx, chaosJobs)
+	if err != nil && ctx.Err() == nil {
+		logFrom(ctx).Errorf("run not started, reason: %s", err.Error())
+		runSpan.SetStatus(codes.Error, err.Error())
 		for _, parallelJobs := range chaosJobs {
 			for _, j := range parallelJobs {
-				wg.Add(1)
-				j := j
-				go func() {
-					logrus.Infof("running  id: %v, job %s", jobStatusId, j.Name)
-					j.Run(jobStatusId)
-					wg.Done()
-				}()
+				j.Status = FailedStatus
+				j.FailedReason = err.Error()
+				j.sendStatus(ctx, jobStatusId)
 			}
-			wg.Wait()
 		}
-	}()
-	c.JSON(http.StatusCreated, NormalResponse(Ok, jobStatusId))
+		return
+	}
+	silences := silenceTargets(ctx, jobStatusId, chaosJobs)
+	defer expireSilences(ctx, silences)
+	runSteps(ctx, chaosJobs, jobStatusId, func(stepCtx context.Context, j *ChaosJob) {
+		h.snapshotTargets(stepCtx, j, jobStatusId, BeforePhase)
+		start := time.Now()
+		stopLogs := h.captureLogs(stepCtx, j, jobStatusId)
+		j.Run(stepCtx, jobStatusId)
+		stopLogs()
+		h.snapshotTargets(stepCtx, j, jobStatusId, AfterPhase)
+		h.captureAppLogs(stepCtx, j, jobStatusId, start)
+	})
+}
+
+// runSteps runs the stages one after another and the steps inside a stage in parallel with run,
+// the steps of the stages left are aborted once ctx is canceled.
+func runSteps(ctx context.Context, chaosJobs [][]ChaosJob, jobStatusId uint, run func(ctx context.Context, chaosJob *ChaosJob)) {
+	var wg sync.WaitGroup
+	for i, parallelJobs := range chaosJobs {
+		if ctx.Err() != nil {
+			// the run is aborted, the steps left will not start
+			for _, j := range parallelJobs {
+				j.Status = AbortedStatus
+				j.FailedReason = abortedReason
+				j.sendStatus(ctx, jobStatusId)
+			}
+			continue
+		}
+		stageCtx, stageSpan := tracer.Start(ctx, fmt.Sprintf("stage %d", i))
+		for _, j := range parallelJobs {
+			wg.Add(1)
+			j := j
+			go func() {
+				defer wg.Done()
+				stepCtx, stepSpan := tracer.Start(stageCtx, "step "+j.Name, trace.WithAttributes(
+					attribute.String("step", j.Name), attribute.String("type", j.Type)))
+				stepCtx = withLogFields(stepCtx, logrus.Fields{StepField: j.Name})
+				logFrom(stepCtx).Info("running step")
+				run(stepCtx, &j)
+				stepSpan.SetAttributes(attribute.String("status", string(j.Status)))
+				if j.Status != SuccessStatus {
+					stepSpan.SetStatus(codes.Error, j.FailedReason)
+				}
+				stepSpan.End()
+			}()
+		}
+		wg.Wait()
+		stageSpan.End()
+	}
+}
+
+func (h *Handler) GetTimeline(c *gin.Context) {
+	id, err := idParam(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse(RequestError, err))
+		return
+	}
+	events, err := h.events.ListByRunId(uint(id))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse(MySqlError, err))
+		return
+	}
+	c.JSON(http.StatusOK, NormalResponse(Ok, events))
+}
+
+func (h *Handler) AbortChaos(c *gin.Context) {
+	id, err := idParam(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse(RequestError, err))
+		return
+	}
+	cancel, ok := h.runCancels.Load(uint(id))
+	if !ok {
+		run, err := h.runs.GetById(uint(id))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse(MySqlError, err))
+			return
+		}
+		if run.Id == 0 {
+			c.AbortWithStatusJSON(http.StatusNotFound, ErrorResponse(RunNotFound, nil))
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusConflict, ErrorResponse(RunNotRunning, nil))
+		return
+	}
+	logFrom(c.Request.Context()).WithField(RunIdField, id).Info("aborting run")
+	cancel.(context.CancelFunc)()
+	c.JSON(http.StatusAccepted, NormalResponse(Ok, id))
 }
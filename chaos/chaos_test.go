@@ -0,0 +1,101 @@
+/*
+ *
+ *  * Licensed to the Apache Software Foundation (ASF) under one
+ *  * or more contributor license agreements.  See the NOTICE file
+ *  * distributed with this work for additional information
+ *  * regarding copyright ownership.  The ASF licenses this file
+ *  * to you under the Apache License, Version 2.0 (the
+ *  * "License"); you may not use this file except in compliance
+ *  * with the License.  You may obtain a copy of the License at
+ *  *
+ *  *     http://www.apache.org/licenses/LICENSE-2.0
+ *  *
+ *  * Unless required by applicable law or agreed to in writing, software
+ *  * distributed under the License is distributed on an "AS IS" BASIS,
+ *  * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  * See the License for the specific language governing permissions and
+ *  * limitations under the License.
+ *
+ *
+ */
+package chaos
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"godzilla/types"
+	batchV1 "k8s.io/api/batch/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"reflect"
+	"testing"
+)
+
+func chaosJobObject(name, jobType, namespace string) *batchV1.Job {
+	return &batchV1.Job{ObjectMeta: metaV1.ObjectMeta{
+		Name:      name,
+		Namespace: "chaos",
+		Labels: map[string]string{
+			"chaos.job.id":        "1",
+			"chaos.job.type":      jobType,
+			"chaos.job.namespace": namespace,
+		},
+	}}
+}
+
+// TestCleanJobGauge cleans the jobs of a run from one of its steps, every job takes its own series off
+// the gauge and the jobs already being deleted are not counted twice.
+func TestCleanJobGauge(t *testing.T) {
+	deleting := chaosJobObject("stress-1", string(types.LitmusPodIoStress), "other")
+	deleting.DeletionTimestamp = &metaV1.Time{}
+	client := fake.NewSimpleClientset(chaosJobObject("delete-1", string(types.LitmusPodDelete), "app"), deleting)
+	ctx := withExecutor(context.Background(), &executor{client: client, namespace: "chaos"})
+	deleteGauge := chaosJobsActive.WithLabelValues(string(types.LitmusPodDelete), "app")
+	stressGauge := chaosJobsActive.WithLabelValues(string(types.LitmusPodIoStress), "other")
+	deleteGauge.Set(1)
+	stressGauge.Set(1)
+
+	step := &ChaosJob{Type: string(types.LitmusPodIoStress), Config: map[string]string{"APP_NAMESPACE": "other"}}
+	if err := step.cleanJob(ctx, 1); err != nil {
+		t.Fatalf("cleanJob() = %v, want nil", err)
+	}
+	if v := testutil.ToFloat64(deleteGauge); v != 0 {
+		t.Errorf("active pod-delete jobs = %v, want 0", v)
+	}
+	if v := testutil.ToFloat64(stressGauge); v != 1 {
+		t.Errorf("active pod-io-stress jobs = %v, want 1", v)
+	}
+}
+
+func TestPreCheck(t *testing.T) {
+	step := func(name, jobType string, config map[string]string) ChaosJob {
+		return ChaosJob{Name: name, Type: jobType, Config: config}
+	}
+	podDelete := string(types.LitmusPodDelete)
+	chaosJobs := [][]ChaosJob{
+		{
+			step("ok", podDelete, map[string]string{"PODS_AFFECTED_PERC": "100", "TOTAL_CHAOS_DURATION": ""}),
+			step("ok", "unknown", nil),
+		},
+		{
+			step("over", podDelete, map[string]string{"PODS_AFFECTED_PERC": "101"}),
+			step("words", podDelete, map[string]string{"TOTAL_CHAOS_DURATION": "30s"}),
+		},
+	}
+	err := preCheck(chaosJobs)
+	validationErr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("preCheck() = %v, want a *ValidationError", err)
+	}
+	var fields []string
+	for _, field := range validationErr.Fields {
+		fields = append(fields, field.Step+" "+field.Field)
+	}
+	want := []string{"ok name", "ok type", "over config.PODS_AFFECTED_PERC", "words config.TOTAL_CHAOS_DURATION"}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("preCheck() field errors = %v, want %v", fields, want)
+	}
+	if err := preCheck(chaosJobs[:0]); err != nil {
+		t.Errorf("preCheck() of no stages = %v, want nil", err)
+	}
+}
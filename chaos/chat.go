@@ -0,0 +1,227 @@
+/*
+ *
+ *  * Licensed to the Apache Software Foundation (ASF) under one
+ *  * or more contributor license agreements.  See the NOTICE file
+ *  * distributed with this work for additional information
+ *  * regarding copyright ownership.  The ASF licenses this file
+ *  * to you under the Apache License, Version 2.0 (the
+ *  * "License"); you may not use this file except in compliance
+ *  * with the License.  You may obtain a copy of the License at
+ *  *
+ *  *     http://www.apache.org/licenses/LICENSE-2.0
+ *  *
+ *  * Unless required by applicable law or agreed to in writing, software
+ *  * distributed under the License is distributed on an "AS IS" BASIS,
+ *  * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  * See the License for the specific language governing permissions and
+ *  * limitations under the License.
+ *
+ *
+ */
+
+package chaos
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"godzilla/db"
+	"godzilla/env"
+	"net/http"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+	"time"
+)
+
+const chatMaxAttempts = 3
+
+// defaultChatTemplate renders the steps as a code block so that it stays aligned in Slack and Mattermost.
+const defaultChatTemplate = `{{emoji .Verdict}} *godzilla {{.Event}}*, run #{{.RunId}} of scenario *{{.Scenario}}*` +
+	`{{if .TriggeredBy}} triggered by {{.TriggeredBy}}{{end}}
+{{- if .Step}}
+step *{{.Step.Name}}* is {{.Step.Status}}{{if .Step.FailedReason}}: {{.Step.FailedReason}}{{end}}
+{{- end}}
+` + "```" + `
+{{table .Stages}}` + "```" + `
+{{- if .RunUrl}}
+<{{.RunUrl}}|run timeline>
+{{- end}}`
+
+// ChatMessage is the data of the chat templates, Stages is the status of the run as the status worker keeps it.
+type ChatMessage struct {
+	Event       string
+	RunId       uint
+	Scenario    string
+	TriggeredBy string
+	Verdict     string
+	Step        *ChaosJob
+	Stages      [][]ChaosJob
+	RunUrl      string
+}
+
+type NotificationBody struct {
+	Scenario string   `json:"scenario" binding:"required"`
+	Url      string   `json:"url" binding:"required,url"`
+	Events   []string `json:"events,omitempty"`
+	Template string   `json:"template,omitempty"`
+}
+
+var chatFuncs = template.FuncMap{
+	"table": statusTable,
+	"emoji": func(verdict string) string {
+		switch verdict {
+		case string(SuccessStatus):
+			return ":white_check_mark:"
+		case string(FailedStatus):
+			return ":x:"
+		case string(AbortedStatus):
+			return ":octagonal_sign:"
+		default:
+			return ":t-rex:"
+		}
+	},
+}
+
+func parseChatTemplate(text string) (*template.Template, error) {
+	if text == "" {
+		text = defaultChatTemplate
+	}
+	return template.New("chat").Funcs(chatFuncs).Parse(text)
+}
+
+// statusTable renders one line per step with its stage, type and status.
+func statusTable(chaosJobs [][]ChaosJob) string {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "STAGE\tSTEP\tTYPE\tSTATUS\tREASON")
+	for i, parallelJobs := range chaosJobs {
+		for _, j := range parallelJobs {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", i+1, j.Name, j.Type, j.Status, j.FailedReason)
+		}
+	}
+	w.Flush()
+	return buf.String()
+}
+
+// notifyChat posts the message to the chat notifications of its scenario subscribed to its event.
+func (h *Handler) notifyChat(message ChatMessage) {
+	notifications, err := h.notifications.ListByScenario(message.Scenario)
+	if err != nil {
+		runLog(message.RunId).Errorf("list chat notifications failed, reason: %s", err.Error())
+		return
+	}
+	var subscribed []db.ChatNotification
+	for _, n := range notifications {
+		for _, event := range n.Events {
+			if event == message.Event {
+				subscribed = append(subscribed, n)
+				break
+			}
+		}
+	}
+	if len(subscribed) == 0 {
+		return
+	}
+	run, err := h.runs.GetById(message.RunId)
+	if err != nil {
+		runLog(message.RunId).Warnf("get run for chat notification failed, reason: %s", err.Error())
+	}
+	message.TriggeredBy = run.TriggeredBy
+	if env.ExternalUrl != "" {
+		message.RunUrl = fmt.Sprintf("%s/api/v1/runs/%v/timeline", strings.TrimSuffix(env.ExternalUrl, "/"), message.RunId)
+	}
+	for _, n := range subscribed {
+		tmpl, err := parseChatTemplate(n.Template)
+		if err != nil {
+			logrus.Errorf("parse template of chat notification %v failed, reason: %s", n.Id, err.Error())
+			continue
+		}
+		var text bytes.Buffer
+		err = tmpl.Execute(&text, message)
+		if err != nil {
+			logrus.Errorf("render chat notification %v failed, reason: %s", n.Id, err.Error())
+			continue
+		}
+		go postChat(n, text.String())
+	}
+}
+
+func postChat(notification db.ChatNotification, text string) {
+	data, _ := json.Marshal(map[string]string{"text": text})
+	for attempt := 1; attempt <= chatMaxAttempts; attempt++ {
+		resp, err := webhookClient.Post(notification.Url, "application/json", bytes.NewReader(data))
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+				return
+			}
+			err = fmt.Errorf("unexpected status code %d", resp.StatusCode)
+		}
+		logrus.Warnf("post chat notification %v failed, attempt %d, reason: %s", notification.Id, attempt, err.Error())
+		time.Sleep(time.Duration(attempt) * time.Second)
+	}
+	logrus.Errorf("chat notification %v dropped after %d attempts", notification.Id, chatMaxAttempts)
+}
+
+func (h *Handler) CreateNotification(c *gin.Context) {
+	var body NotificationBody
+	if !bindJSON(c, &body) {
+		return
+	}
+	if len(body.Events) == 0 {
+		body.Events = []string{RunFinishedEvent, RunAbortedEvent}
+	}
+	var validationErr ValidationError
+	for _, event := range body.Events {
+		if !webhookEvents[event] {
+			validationErr.add("", "events", "unsupported event %s", event)
+		}
+	}
+	_, err := parseChatTemplate(body.Template)
+	if err != nil {
+		validationErr.add("", "template", "%s", err.Error())
+	}
+	err = validationErr.err()
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, ErrorResponse(RequestError, err))
+		return
+	}
+	notification := db.ChatNotification{
+		Scenario: body.Scenario,
+		Url:      body.Url,
+		Events:   body.Events,
+		Template: body.Template,
+	}
+	err = h.notifications.Add(&notification)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse(MySqlSaveError, err))
+		return
+	}
+	c.JSON(http.StatusCreated, NormalResponse(Ok, notification))
+}
+
+func (h *Handler) ListNotifications(c *gin.Context) {
+	notifications, err := h.notifications.List()
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse(MySqlError, err))
+		return
+	}
+	c.JSON(http.StatusOK, NormalResponse(Ok, notifications))
+}
+
+func (h *Handler) DeleteNotification(c *gin.Context) {
+	id, err := idParam(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse(RequestError, err))
+		return
+	}
+	err = h.notifications.DeleteById(uint(id))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse(MySqlError, err))
+		return
+	}
+	c.JSON(http.StatusOK, NormalResponse(Ok, id))
+}
@@ -0,0 +1,137 @@
+/*
+ *
+ *  * Licensed to the Apache Software Foundation (ASF) under one
+ *  * or more contributor license agreements.  See the NOTICE file
+ *  * distributed with this work for additional information
+ *  * regarding copyright ownership.  The ASF licenses this file
+ *  * to you under the Apache License, Version 2.0 (the
+ *  * "License"); you may not use this file except in compliance
+ *  * with the License.  You may obtain a copy of the License at
+ *  *
+ *  *     http://www.apache.org/licenses/LICENSE-2.0
+ *  *
+ *  * Unless required by applicable law or agreed to in writing, software
+ *  * distributed under the License is distributed on an "AS IS" BASIS,
+ *  * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  * See the License for the specific language governing permissions and
+ *  * limitations under the License.
+ *
+ *
+ */
+package chaos
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"godzilla/db"
+	"godzilla/env"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stubChat returns the url of an incoming webhook stand-in and the texts posted to it.
+func stubChat(t *testing.T) (string, chan string) {
+	t.Helper()
+	texts := make(chan string, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		texts <- payload["text"]
+	}))
+	t.Cleanup(server.Close)
+	return server.URL + "/hooks/secret-token", texts
+}
+
+func TestNotifyChat(t *testing.T) {
+	externalUrl := env.ExternalUrl
+	env.ExternalUrl = "https://godzilla.example.com/"
+	t.Cleanup(func() { env.ExternalUrl = externalUrl })
+	store := db.NewMemoryStore()
+	h := NewHandler(store, nil, nil)
+	url, texts := stubChat(t)
+	err := store.Notifications.Add(&db.ChatNotification{Scenario: "kill-nginx", Url: url, Events: []string{RunFinishedEvent}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	run := db.JobStatus{ScenarioId: 1, TriggeredBy: "ci"}
+	if err := store.Runs.Add(&run); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		step ChaosJob
+		want []string
+	}{
+		{
+			"success",
+			ChaosJob{Name: "kill", Type: "litmus-pod-delete", Status: SuccessStatus},
+			[]string{":white_check_mark: *godzilla run.finished*, run #1 of scenario *kill-nginx* triggered by ci",
+				"1      kill  litmus-pod-delete  success",
+				"<https://godzilla.example.com/api/v1/runs/1/timeline|run timeline>"},
+		},
+		{
+			"failed",
+			ChaosJob{Name: "kill", Type: "litmus-pod-delete", Status: FailedStatus, FailedReason: "no pods found"},
+			[]string{":x: *godzilla run.finished*, run #1 of scenario *kill-nginx* triggered by ci",
+				"1      kill  litmus-pod-delete  failed  no pods found"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stages := [][]ChaosJob{{tt.step}}
+			h.notifyChat(ChatMessage{
+				Event:    RunFinishedEvent,
+				RunId:    run.Id,
+				Scenario: "kill-nginx",
+				Verdict:  runVerdict(stages),
+				Stages:   stages,
+			})
+			select {
+			case text := <-texts:
+				for _, want := range tt.want {
+					if !strings.Contains(text, want) {
+						t.Errorf("chat text\n%s\nwant it to contain %q", text, want)
+					}
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("no chat message posted")
+			}
+		})
+	}
+	// a step event is not subscribed
+	h.notifyChat(ChatMessage{Event: StepStatusEvent, RunId: run.Id, Scenario: "kill-nginx"})
+	select {
+	case text := <-texts:
+		t.Errorf("unsubscribed event posted %q", text)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestListNotificationsRedactsUrl(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := db.NewMemoryStore()
+	h := NewHandler(store, nil, nil)
+	err := store.Notifications.Add(&db.ChatNotification{
+		Scenario: "kill-nginx",
+		Url:      "https://hooks.slack.com/services/T000/B000/secret-token",
+		Events:   []string{RunFinishedEvent},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := gin.New()
+	router.GET("/notifications", h.ListNotifications)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/notifications", nil))
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), "secret-token") ||
+		!strings.Contains(w.Body.String(), `"host":"hooks.slack.com"`) {
+		t.Errorf("status code = %d, body %s, want 200 with only the host of the url", w.Code, w.Body.String())
+	}
+}
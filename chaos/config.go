@@ -22,6 +22,8 @@
 package chaos
 
 import (
+	"context"
+	"fmt"
 	"github.com/sirupsen/logrus"
 	"godzilla/env"
 	"k8s.io/client-go/kubernetes"
@@ -38,23 +40,32 @@ var (
 func InitKubeClient() {
 	if client == nil {
 		fetchConfig()
+		initEventRecorder()
 	}
 }
 
 func ReadyChaosEnv(namespace string) {
-	// setup rbac
-	err := addClusterRole()
+	err := readyChaosEnv(context.Background(), client, namespace)
 	if err != nil {
-		logrus.Fatalf("create cluster role failed, reason: %s", err.Error())
+		logrus.Fatal(err.Error())
 	}
-	err = addServiceAccount(namespace)
+}
+
+// readyChaosEnv sets up the service account of the chaos jobs in the namespace, with its rbac.
+func readyChaosEnv(ctx context.Context, client kubernetes.Interface, namespace string) error {
+	err := addClusterRole(ctx, client)
+	if err != nil {
+		return fmt.Errorf("create cluster role failed, reason: %s", err.Error())
+	}
+	err = addServiceAccount(ctx, client, namespace)
 	if err != nil {
-		logrus.Fatalf("create service account failed, reason: %s", err.Error())
+		return fmt.Errorf("create service account failed, reason: %s", err.Error())
 	}
-	err = addRoleBinding(namespace)
+	err = addRoleBinding(ctx, client, namespace)
 	if err != nil {
-		logrus.Fatalf("create role binding failed, reason: %s", err.Error())
+		return fmt.Errorf("create role binding failed, reason: %s", err.Error())
 	}
+	return nil
 }
 
 func fetchConfig() {
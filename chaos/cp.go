@@ -30,7 +30,12 @@ import (
 	"os"
 )
 
-func copyIntoPod(podName string, namespace string, container string, srcPath string, dstPath string) {
+func copyIntoPod(ctx context.Context, podName string, namespace string, container string, srcPath string, dstPath string) {
+	kube := executorFrom(ctx)
+	if kube.config == nil {
+		logrus.Errorf("copy into pod %s failed, reason: no kube config to exec with", podName)
+		return
+	}
 	localFile, err := os.Open(srcPath)
 	if err != nil {
 		logrus.Errorf("error opening local file: %s", err)
@@ -39,7 +44,7 @@ func copyIntoPod(podName string, namespace string, container string, srcPath str
 	defer localFile.Close()
 
 	// Create a stream to the container
-	req := client.CoreV1().RESTClient().Post().
+	req := kube.client.CoreV1().RESTClient().Post().
 		Resource("pods").
 		Name(podName).
 		Namespace(namespace).
@@ -54,14 +59,14 @@ func copyIntoPod(podName string, namespace string, container string, srcPath str
 		Stderr:    true,
 	}, scheme.ParameterCodec)
 
-	exec, err := remotecommand.NewSPDYExecutor(config, "POST", req.URL())
+	exec, err := remotecommand.NewSPDYExecutor(kube.config, "POST", req.URL())
 	if err != nil {
 		logrus.Errorf("error creating executor: %s", err)
 		return
 	}
 
 	// Create a stream to the container
-	err = exec.StreamWithContext(context.TODO(), remotecommand.StreamOptions{
+	err = exec.StreamWithContext(ctx, remotecommand.StreamOptions{
 		Stdin:  localFile,
 		Stdout: os.Stdout,
 		Stderr: os.Stderr,
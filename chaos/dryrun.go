@@ -0,0 +1,208 @@
+/*
+ *
+ *  * Licensed to the Apache Software Foundation (ASF) under one
+ *  * or more contributor license agreements.  See the NOTICE file
+ *  * distributed with this work for additional information
+ *  * regarding copyright ownership.  The ASF licenses this file
+ *  * to you under the Apache License, Version 2.0 (the
+ *  * "License"); you may not use this file except in compliance
+ *  * with the License.  You may obtain a copy of the License at
+ *  *
+ *  *     http://www.apache.org/licenses/LICENSE-2.0
+ *  *
+ *  * Unless required by applicable law or agreed to in writing, software
+ *  * distributed under the License is distributed on an "AS IS" BASIS,
+ *  * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  * See the License for the specific language governing permissions and
+ *  * limitations under the License.
+ *
+ *
+ */
+
+package chaos
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"godzilla/types"
+	"gopkg.in/yaml.v3"
+	batchV1 "k8s.io/api/batch/v1"
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"net/http"
+	k8sYaml "sigs.k8s.io/yaml"
+	"strings"
+)
+
+// DryRun is what a run would do, nothing of it is created.
+type DryRun = types.DryRun
+
+// DryRunStep is a step with its resolved config and targets, and the chaos jobs it would create.
+type DryRunStep = types.DryRunStep
+
+type DryRunTarget = types.DryRunTarget
+
+var jobTypeMeta = metaV1.TypeMeta{APIVersion: "batch/v1", Kind: "Job"}
+
+// planStep resolves the targets of the step the way runLitmusCommon and runLitmusStress do and builds
+// the manifests of their chaos jobs, the run id of the manifests is 0.
+func planStep(ctx context.Context, chaosJob ChaosJob) DryRunStep {
+	chaosJob = chaosJob.copy()
+	namespace := executorFrom(ctx).namespace
+	step := DryRunStep{
+		Name:               chaosJob.Name,
+		Type:               chaosJob.Type,
+		Image:              chaosJob.Image,
+		ServiceAccountName: chaosJob.ServiceAccountName,
+		Config:             chaosJob.Config,
+		Jobs:               []json.RawMessage{},
+	}
+	switch chaosJob.Type {
+	case string(types.LitmusPodDelete):
+		if chaosJob.Config["TARGET_PODS"] != "" {
+			for _, targetPod := range strings.Split(chaosJob.Config["TARGET_PODS"], ",") {
+				step.Targets = append(step.Targets, DryRunTarget{Pod: strings.TrimSpace(targetPod)})
+			}
+		} else {
+			_, allPods, err := stressTargets(ctx, &chaosJob)
+			if err != nil {
+				step.Error = err.Error()
+			}
+			for _, pod := range allPods {
+				step.Candidates = append(step.Candidates, pod.Name)
+			}
+		}
+		addJob(&step, chaosJob.LitmusJob(namespace, 0))
+	case string(types.LitmusPodIoStress):
+		pods, _, err := stressTargets(ctx, &chaosJob)
+		if err != nil {
+			step.Error = err.Error()
+			return step
+		}
+		for i := range pods {
+			if pods[i].Status.Phase != coreV1.PodRunning {
+				continue
+			}
+			// each pod gets its own config, the one of the step is kept as resolved
+			podJob := chaosJob.copy()
+			podJob.stressConfig(&pods[i])
+			addJob(&step, podJob.LitmusJobStress(namespace, 0, pods[i].Spec.NodeName, pods[i].Name))
+			step.Targets = append(step.Targets, DryRunTarget{Pod: pods[i].Name, Node: pods[i].Spec.NodeName})
+		}
+	}
+	return step
+}
+
+// addJob adds the manifest of the job to the step, with its kind so that it can be applied as is.
+func addJob(step *DryRunStep, job batchV1.Job) {
+	job.TypeMeta = jobTypeMeta
+	data, err := json.Marshal(job)
+	if err != nil {
+		step.Error = err.Error()
+		return
+	}
+	step.Jobs = append(step.Jobs, data)
+}
+
+// respondDryRun answers the plan of the run, or with format=yaml only the manifests of the chaos jobs.
+func respondDryRun(c *gin.Context, scenario string, chaosJobs [][]ChaosJob) {
+	ctx := withLogFields(c.Request.Context(), logrus.Fields{ScenarioField: scenario})
+	dryRun := DryRun{Scenario: scenario}
+	refusal := alertsCheck(ctx, chaosJobs)
+	if refusal != nil {
+		dryRun.Refused = refusal.Error()
+	}
+	for _, parallelJobs := range chaosJobs {
+		steps := make([]DryRunStep, 0, len(parallelJobs))
+		for _, j := range parallelJobs {
+			steps = append(steps, planStep(withLogFields(ctx, logrus.Fields{StepField: j.Name}), j))
+		}
+		dryRun.Stages = append(dryRun.Stages, steps)
+	}
+	if c.Query("format") != "yaml" {
+		c.JSON(http.StatusOK, NormalResponse(Ok, dryRun))
+		return
+	}
+	// the manifests alone would not tell that the run is refused or a step could not be planned
+	if refusal != nil {
+		c.AbortWithStatusJSON(http.StatusConflict, ErrorResponse(AlertsFiring, refusal, refusal.Error()))
+		return
+	}
+	var buf bytes.Buffer
+	for _, steps := range dryRun.Stages {
+		for _, step := range steps {
+			if step.Error != "" {
+				c.AbortWithStatusJSON(http.StatusConflict, ErrorResponse(DryRunStepError, errors.New(step.Error), step.Name))
+				return
+			}
+			for _, job := range step.Jobs {
+				data, err := k8sYaml.JSONToYAML(job)
+				if err != nil {
+					c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse(YamlMarshalError, err))
+					return
+				}
+				buf.WriteString("---\n")
+				buf.Write(data)
+			}
+		}
+	}
+	c.Data(http.StatusOK, "application/yaml; charset=utf-8", buf.Bytes())
+}
+
+// DryRunChaos previews a run of a stored scenario, it takes the body of CreateChaos.
+func (h *Handler) DryRunChaos(c *gin.Context) {
+	body := ChaosBody{Scenario: c.Param("name")}
+	// the body is optional on the /api/v1 route
+	if body.Scenario == "" || c.Request.ContentLength != 0 {
+		if !bindJSON(c, &body) {
+			return
+		}
+	}
+	if name := c.Param("name"); name != "" {
+		body.Scenario = name
+	}
+	s, err := h.scenarios.GetByName(body.Scenario)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, ErrorResponse(ReadFileError, err))
+		return
+	}
+	if s.Id == 0 {
+		c.AbortWithStatusJSON(http.StatusNotFound, ErrorResponse(ScenarioNotFound, nil))
+		return
+	}
+	var chaosJobs [][]ChaosJob
+	err = yaml.Unmarshal([]byte(s.Definition), &chaosJobs)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse(YamlUnmarshalError, err))
+		return
+	}
+	// the steps of the yaml may have no config at all
+	chaosJobs = copyStages(chaosJobs)
+	overrideConfig(chaosJobs, body)
+	err = preCheck(chaosJobs)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, ErrorResponse(InvalidScenario, err))
+		return
+	}
+	respondDryRun(c, body.Scenario, chaosJobs)
+}
+
+// DryRunChaosOne previews a run of the stages of the body, like CreateChaosOne.
+func (h *Handler) DryRunChaosOne(c *gin.Context) {
+	var chaosJobs [][]ChaosJob
+	if !bindJSON(c, &chaosJobs) {
+		return
+	}
+	chaosJobs = copyStages(chaosJobs)
+	overrideConfigOne(chaosJobs)
+	err := preCheck(chaosJobs)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, ErrorResponse(InvalidScenario, err))
+		return
+	}
+	respondDryRun(c, adHocScenario, chaosJobs)
+}
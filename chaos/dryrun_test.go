@@ -0,0 +1,140 @@
+/*
+ *
+ *  * Licensed to the Apache Software Foundation (ASF) under one
+ *  * or more contributor license agreements.  See the NOTICE file
+ *  * distributed with this work for additional information
+ *  * regarding copyright ownership.  The ASF licenses this file
+ *  * to you under the Apache License, Version 2.0 (the
+ *  * "License"); you may not use this file except in compliance
+ *  * with the License.  You may obtain a copy of the License at
+ *  *
+ *  *     http://www.apache.org/licenses/LICENSE-2.0
+ *  *
+ *  * Unless required by applicable law or agreed to in writing, software
+ *  * distributed under the License is distributed on an "AS IS" BASIS,
+ *  * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  * See the License for the specific language governing permissions and
+ *  * limitations under the License.
+ *
+ *
+ */
+package chaos
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"godzilla/db"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// dryRunRouter serves the dry-run endpoints with the fake clientset as the kube client of the requests.
+func dryRunRouter(t *testing.T, clientset *fake.Clientset) (*gin.Engine, *Handler) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	h := NewHandler(db.NewMemoryStore(), nil, nil)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		e := &executor{client: clientset, namespace: "chaos"}
+		c.Request = c.Request.WithContext(withExecutor(c.Request.Context(), e))
+	})
+	router.POST("/scenarios/:name/dry-run", h.DryRunChaos)
+	router.POST("/dry-run", h.DryRunChaosOne)
+	return router, h
+}
+
+func postDryRun(router *gin.Engine, path, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, r)
+	return w
+}
+
+func TestDryRunChaos(t *testing.T) {
+	clientset := fake.NewSimpleClientset(readyPod("nginx-1"), readyPod("nginx-2"))
+	router, h := dryRunRouter(t, clientset)
+	err := h.scenarios.Add(&db.Scenario{Name: "stress", Definition: `
+- - name: stress-nginx
+    type: litmus-pod-io-stress
+    config:
+      APP_NAMESPACE: app
+      APP_LABEL: app=nginx
+      PODS_AFFECTED_PERC: "50"
+`})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := postDryRun(router, "/scenarios/stress/dry-run", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status code = %d, want 200, body %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Body DryRun `json:"body"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	step := resp.Body.Stages[0][0]
+	if step.Error != "" || len(step.Targets) != 1 || len(step.Jobs) != 1 {
+		t.Errorf("step = %+v, want one target and its chaos job", step)
+	}
+	jobs, err := clientset.BatchV1().Jobs("chaos").List(context.Background(), metaV1.ListOptions{})
+	if err != nil || len(jobs.Items) != 0 {
+		t.Errorf("dry run created the chaos jobs %v, %v, want none", jobs, err)
+	}
+
+	w = postDryRun(router, "/scenarios/missing/dry-run", "")
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status code of a missing scenario = %d, want 404", w.Code)
+	}
+}
+
+func TestDryRunChaosOne(t *testing.T) {
+	router, _ := dryRunRouter(t, fake.NewSimpleClientset(readyPod("nginx-1")))
+	tests := []struct {
+		name     string
+		format   string
+		config   string
+		wantCode int
+		wantBody string
+	}{
+		{"planned", "", `"APP_LABEL": "app=nginx"`, http.StatusOK, `"targets":[{"pod":"nginx-1","node":"node"}]`},
+		{"manifests", "yaml", `"APP_LABEL": "app=nginx"`, http.StatusOK, "kind: Job"},
+		{"percentage out of range", "", `"PODS_AFFECTED_PERC": "101"`, http.StatusUnprocessableEntity, "config.PODS_AFFECTED_PERC"},
+		{"step error", "", `"TARGET_PODS": "missing"`, http.StatusOK, `"error":"pods \"missing\" not found"`},
+		{"step error as manifests", "yaml", `"TARGET_PODS": "missing"`, http.StatusConflict, "dry_run_step_error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `[[{"name": "step", "type": "litmus-pod-io-stress", "config": {"APP_NAMESPACE": "app", ` + tt.config + `}}]]`
+			w := postDryRun(router, "/dry-run?format="+tt.format, body)
+			if w.Code != tt.wantCode || !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("status code = %d, body %s, want %d with %s", w.Code, w.Body.String(), tt.wantCode, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestDryRunRefusedManifests(t *testing.T) {
+	router, _ := dryRunRouter(t, fake.NewSimpleClientset(readyPod("nginx-1")))
+	withAlertmanager(t, &stubAlertmanager{firing: func(string) []alert {
+		return []alert{{Labels: map[string]string{"alertname": "HighErrorRate", "severity": "critical"}}}
+	}}, "", "")
+
+	body := `[[{"name": "step", "type": "litmus-pod-delete", "config": {"APP_NAMESPACE": "app", "APP_LABEL": "app=nginx"}}]]`
+	w := postDryRun(router, "/dry-run", body)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"refused":"alerts firing: app/HighErrorRate"`) {
+		t.Errorf("status code = %d, body %s, want 200 with the refusal", w.Code, w.Body.String())
+	}
+	w = postDryRun(router, "/dry-run?format=yaml", body)
+	if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), "alerts_firing") {
+		t.Errorf("status code with format=yaml = %d, body %s, want 409 alerts_firing", w.Code, w.Body.String())
+	}
+}
@@ -22,19 +22,133 @@
 package chaos
 
 import (
+	"errors"
 	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
 	"github.com/sirupsen/logrus"
+	"godzilla/types"
+	"net/http"
+	"reflect"
+	"strings"
 )
 
+// ErrorResponse carries the machine-readable code, the message of err as the cause and, for
+// a ValidationError or a failed binding, the fields which are not valid.
 func ErrorResponse(code int, err error, a ...any) Response {
+	respErr := responseError{code: code}
+	resp := Response{
+		Status:  types.ErrorStatus,
+		Message: respErr.Error(a...),
+		Code:    errorCodeMap[code],
+	}
 	if err != nil {
 		logrus.Errorln(err)
+		resp.Cause = err.Error()
+		resp.Details = fieldErrors(err)
 	}
-	respErr := responseError{code: code}
+	return resp
+}
 
-	return Response{
-		Status:  errorStatus,
-		Message: respErr.Error(a...),
+// FieldError is the detail of a ValidationError in the responses.
+type FieldError = types.FieldError
+
+// ValidationError is an error of the content of a request, answered with 422 and its fields.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		messages = append(messages, field.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+// add records the field error, fmt formats the message.
+func (e *ValidationError) add(step, field, format string, a ...any) {
+	e.Fields = append(e.Fields, FieldError{Step: step, Field: field, Message: fmt.Sprintf(format, a...)})
+}
+
+// err returns nil if no field error was recorded.
+func (e *ValidationError) err() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
+
+func fieldErrors(err error) []FieldError {
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		return validationErr.Fields
+	}
+	fields, _ := bindingErrors(err)
+	return fields
+}
+
+// bindingErrors collects the fields breaking the binding rules, ok is false if err is not about them.
+func bindingErrors(err error) (fields []FieldError, ok bool) {
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		for _, e := range validationErrs {
+			fields = append(fields, FieldError{
+				Field:   e.Field(),
+				Message: fmt.Sprintf("%s failed on the %s rule", e.Field(), e.Tag()),
+			})
+		}
+		return fields, true
+	}
+	// the errors of the elements when a list is bound
+	var sliceErr binding.SliceValidationError
+	if errors.As(err, &sliceErr) {
+		for _, e := range sliceErr {
+			elementFields, ok := bindingErrors(e)
+			if !ok {
+				return nil, false
+			}
+			fields = append(fields, elementFields...)
+		}
+		return fields, true
+	}
+	return nil, false
+}
+
+// httpStatus is 422 for a request which is well-formed but not valid, otherwise fallback.
+func httpStatus(err error, fallback int) int {
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		return http.StatusUnprocessableEntity
+	}
+	if _, ok := bindingErrors(err); ok {
+		return http.StatusUnprocessableEntity
+	}
+	return fallback
+}
+
+// bindJSON decodes the json body into obj, a body which can not be decoded is answered with 400 and
+// one which breaks the binding rules with 422.
+func bindJSON(c *gin.Context, obj any) bool {
+	err := c.ShouldBindJSON(obj)
+	if err != nil {
+		c.AbortWithStatusJSON(httpStatus(err, http.StatusBadRequest), ErrorResponse(RequestError, err))
+		return false
+	}
+	return true
+}
+
+func init() {
+	// name the fields of the binding errors after their json keys
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "" || name == "-" {
+				return field.Name
+			}
+			return name
+		})
 	}
 }
 
@@ -50,20 +164,68 @@ const (
 	InvalidScenario
 	ChaosJobRunError
 	ScenarioNotFound
+	RunNotFound
+	RunNotFinished
+	RunPurgeError
+	RunNotRunning
+	AlertsFiring
+	LogNotFound
+	LogStoreError
+	EvidenceNotFound
+	ArtifactStoreError
+	ReportError
+	DryRunStepError
 )
 
 var errorMsgMap = map[int]string{
 	RequestError:       "request error",
 	YamlMarshalError:   "yaml marshal error",
 	YamlUnmarshalError: "yaml unmarshal error",
-	MySqlSaveError:     "failed to save to mysql",
+	MySqlSaveError:     "failed to save to database",
 	JsonMarshalError:   "json marshal error",
 	MySqlDataNotFound:  "data not found in database",
-	MySqlError:         "mysql error",
+	MySqlError:         "database error",
 	ReadFileError:      "read file error",
 	InvalidScenario:    "invalid scenario",
 	ChaosJobRunError:   "chaos job run failed",
 	ScenarioNotFound:   "scenario not found",
+	RunNotFound:        "run not found",
+	RunNotFinished:     "run is not finished yet",
+	RunPurgeError:      "failed to purge run",
+	RunNotRunning:      "run is not running on this instance",
+	AlertsFiring:       "refused to run, %s",
+	LogNotFound:        "logs not found",
+	LogStoreError:      "log store error",
+	EvidenceNotFound:   "evidence not found",
+	ArtifactStoreError: "artifact store error",
+	ReportError:        "failed to render the report",
+	DryRunStepError:    "step %s can not be planned",
+}
+
+// errorCodeMap holds the machine-readable codes of the errors, they do not change with the messages.
+var errorCodeMap = map[int]string{
+	RequestError:       "request_error",
+	YamlMarshalError:   "yaml_marshal_error",
+	YamlUnmarshalError: "yaml_unmarshal_error",
+	JsonMarshalError:   "json_marshal_error",
+	MySqlSaveError:     "database_save_error",
+	MySqlDataNotFound:  "database_data_not_found",
+	MySqlError:         "database_error",
+	ReadFileError:      "read_file_error",
+	InvalidScenario:    "invalid_scenario",
+	ChaosJobRunError:   "chaos_job_run_error",
+	ScenarioNotFound:   "scenario_not_found",
+	RunNotFound:        "run_not_found",
+	RunNotFinished:     "run_not_finished",
+	RunPurgeError:      "run_purge_error",
+	RunNotRunning:      "run_not_running",
+	AlertsFiring:       "alerts_firing",
+	LogNotFound:        "log_not_found",
+	LogStoreError:      "log_store_error",
+	EvidenceNotFound:   "evidence_not_found",
+	ArtifactStoreError: "artifact_store_error",
+	ReportError:        "report_error",
+	DryRunStepError:    "dry_run_step_error",
 }
 
 type responseError struct {
@@ -0,0 +1,295 @@
+/*
+ *
+ *  * Licensed to the Apache Software Foundation (ASF) under one
+ *  * or more contributor license agreements.  See the NOTICE file
+ *  * distributed with this work for additional information
+ *  * regarding copyright ownership.  The ASF licenses this file
+ *  * to you under the Apache License, Version 2.0 (the
+ *  * "License"); you may not use this file except in compliance
+ *  * with the License.  You may obtain a copy of the License at
+ *  *
+ *  *     http://www.apache.org/licenses/LICENSE-2.0
+ *  *
+ *  * Unless required by applicable law or agreed to in writing, software
+ *  * distributed under the License is distributed on an "AS IS" BASIS,
+ *  * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  * See the License for the specific language governing permissions and
+ *  * limitations under the License.
+ *
+ *
+ */
+
+package chaos
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"go.opentelemetry.io/otel/attribute"
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedCoreV1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ChaosInjectedReason = "ChaosInjected"
+	ChaosRevertedReason = "ChaosReverted"
+)
+
+var recorder record.EventRecorder
+
+func initEventRecorder() {
+	recorder = newEventRecorder(client)
+}
+
+func newEventRecorder(client kubernetes.Interface) record.EventRecorder {
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedCoreV1.EventSinkImpl{Interface: client.CoreV1().Events("")})
+	return broadcaster.NewRecorder(scheme.Scheme, coreV1.EventSource{Component: "godzilla"})
+}
+
+type scenarioKey struct{}
+
+// withScenario keeps the scenario name of the run in the context of its steps.
+func withScenario(ctx context.Context, scenario string) context.Context {
+	return context.WithValue(ctx, scenarioKey{}, scenario)
+}
+
+func scenarioFrom(ctx context.Context) string {
+	scenario, _ := ctx.Value(scenarioKey{}).(string)
+	return scenario
+}
+
+// chaosTargets keeps the pods a step injected chaos into, they are reverted when the step ends.
+type chaosTargets struct {
+	mu   sync.Mutex
+	pods map[string]*coreV1.Pod
+}
+
+func newChaosTargets() *chaosTargets {
+	return &chaosTargets{pods: make(map[string]*coreV1.Pod)}
+}
+
+// add returns false if the pod is already a target.
+func (t *chaosTargets) add(pod *coreV1.Pod) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.pods[pod.Name]; ok {
+		return false
+	}
+	t.pods[pod.Name] = pod.DeepCopy()
+	return true
+}
+
+func (t *chaosTargets) list() []*coreV1.Pod {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var pods []*coreV1.Pod
+	for _, pod := range t.pods {
+		pods = append(pods, pod)
+	}
+	return pods
+}
+
+func (chaosJob *ChaosJob) eventAnnotations(ctx context.Context, jobStatusId uint) map[string]string {
+	return map[string]string{
+		"godzilla.io/run-id":   fmt.Sprintf("%v", jobStatusId),
+		"godzilla.io/scenario": scenarioFrom(ctx),
+		"godzilla.io/step":     chaosJob.Name,
+	}
+}
+
+// injectTarget marks the pod as a target and records the ChaosInjected event on it
+// and on the controllers owning it.
+func (chaosJob *ChaosJob) injectTarget(ctx context.Context, jobStatusId uint, targets *chaosTargets, pod *coreV1.Pod) {
+	if !targets.add(pod) {
+		return
+	}
+	if pod.DeletionTimestamp == nil {
+		err := chaosJob.annotatePod(ctx, jobStatusId, pod)
+		if err != nil && !errors.IsNotFound(err) {
+			logFrom(ctx).WithField(TargetPodField, pod.Name).Warnf("annotate pod failed, reason: %s", err.Error())
+		}
+	}
+	recorder := executorFrom(ctx).recorder
+	if recorder == nil {
+		return
+	}
+	annotations := chaosJob.eventAnnotations(ctx, jobStatusId)
+	message := fmt.Sprintf("%s injected into pod %s by godzilla, run id %v, scenario %s, step %s",
+		chaosJob.Type, pod.Name, jobStatusId, scenarioFrom(ctx), chaosJob.Name)
+	recorder.AnnotatedEventf(pod, annotations, coreV1.EventTypeWarning, ChaosInjectedReason, "%s", message)
+	for _, owner := range ownersOf(ctx, pod) {
+		recorder.AnnotatedEventf(owner, annotations, coreV1.EventTypeWarning, ChaosInjectedReason, "%s", message)
+	}
+}
+
+// revertTargets removes the marks of the targets and records the ChaosReverted event on the targets
+// still alive and on their owners. It also runs when the step is aborted, so the context is not cancelable.
+func (chaosJob *ChaosJob) revertTargets(ctx context.Context, jobStatusId uint, targets *chaosTargets) {
+	ctx = context.WithoutCancel(ctx)
+	recorder := executorFrom(ctx).recorder
+	annotations := chaosJob.eventAnnotations(ctx, jobStatusId)
+	message := fmt.Sprintf("%s reverted by godzilla, run id %v, scenario %s, step %s",
+		chaosJob.Type, jobStatusId, scenarioFrom(ctx), chaosJob.Name)
+	owners := make(map[types.UID]bool)
+	for _, pod := range targets.list() {
+		err := unannotatePod(ctx, pod)
+		if err != nil {
+			if !errors.IsNotFound(err) {
+				logFrom(ctx).WithField(TargetPodField, pod.Name).Warnf("remove annotations of pod failed, reason: %s", err.Error())
+			}
+		} else if recorder != nil {
+			recorder.AnnotatedEventf(pod, annotations, coreV1.EventTypeNormal, ChaosRevertedReason, "%s", message)
+		}
+		if recorder == nil {
+			continue
+		}
+		for _, owner := range ownersOf(ctx, pod) {
+			if owners[owner.UID] {
+				continue
+			}
+			owners[owner.UID] = true
+			recorder.AnnotatedEventf(owner, annotations, coreV1.EventTypeNormal, ChaosRevertedReason, "%s", message)
+		}
+	}
+}
+
+// ownersOf walks up the controllers of the pod, e.g. its ReplicaSet and then the Deployment.
+func ownersOf(ctx context.Context, pod *coreV1.Pod) []*coreV1.ObjectReference {
+	var refs []*coreV1.ObjectReference
+	owner := metaV1.GetControllerOf(pod)
+	for owner != nil {
+		refs = append(refs, &coreV1.ObjectReference{
+			Kind:       owner.Kind,
+			APIVersion: owner.APIVersion,
+			Name:       owner.Name,
+			Namespace:  pod.Namespace,
+			UID:        owner.UID,
+		})
+		if owner.Kind != "ReplicaSet" {
+			break
+		}
+		kubeCtx, done := kubeCall(ctx, "get_replicaset", attribute.String("replicaset", owner.Name))
+		rs, err := executorFrom(ctx).client.AppsV1().ReplicaSets(pod.Namespace).Get(kubeCtx, owner.Name, metaV1.GetOptions{})
+		done(err)
+		if err != nil {
+			logFrom(ctx).Warnf("get owner of replicaset %s failed, reason: %s", owner.Name, err.Error())
+			break
+		}
+		owner = metaV1.GetControllerOf(rs)
+	}
+	return refs
+}
+
+// killedPodLine is logged by the litmus pod-delete runner for every pod it kills, the name of the pod is in
+// its PodName field, e.g. level=info msg="[Info]: Killing the following pods" PodName=nginx-7c5b6-x2x7q
+const killedPodLine = "Killing the following pods"
+
+var killedPodPattern = regexp.MustCompile(`"?PodName"?\s*[=:]\s*"?([a-z0-9]([-a-z0-9.]*[a-z0-9])?)`)
+
+// killedPod returns the pod the line of the runner reports as killed, empty for the other lines.
+func killedPod(line string) string {
+	if !strings.Contains(line, killedPodLine) {
+		return ""
+	}
+	match := killedPodPattern.FindStringSubmatch(line)
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
+
+// followTargets injects the pods killed by a pod-delete step. The litmus runner picks the victims by itself,
+// so they are taken from its log, the other deletions of APP_LABEL pods, e.g. by a rollout, are left alone.
+func (chaosJob *ChaosJob) followTargets(ctx context.Context, jobStatusId uint, targets *chaosTargets) {
+	kube := executorFrom(ctx)
+	kubeCtx, done := kubeCall(ctx, "watch_pods")
+	w, err := kube.client.CoreV1().Pods(kube.namespace).Watch(kubeCtx, metaV1.ListOptions{
+		LabelSelector: fmt.Sprintf("chaos.job.id=%v,chaos.job.name=%s", jobStatusId, chaosJob.Name),
+	})
+	done(err)
+	if err != nil {
+		logFrom(ctx).Warnf("watch chaos job pods for targets failed, reason: %s", err.Error())
+		return
+	}
+	defer w.Stop()
+	var streams sync.WaitGroup
+	defer streams.Wait()
+	followed := make(map[string]bool)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-w.ResultChan():
+			if !ok {
+				return
+			}
+			pod, ok := event.Object.(*coreV1.Pod)
+			if !ok || event.Type == watch.Deleted || followed[pod.Name] {
+				continue
+			}
+			if pod.Status.Phase != coreV1.PodRunning && pod.Status.Phase != coreV1.PodSucceeded &&
+				pod.Status.Phase != coreV1.PodFailed {
+				continue
+			}
+			followed[pod.Name] = true
+			streams.Add(1)
+			go func(runnerPod string) {
+				defer streams.Done()
+				err := chaosJob.followRunnerLog(ctx, jobStatusId, targets, runnerPod)
+				if err != nil && ctx.Err() == nil {
+					logFrom(ctx).Warnf("follow log of runner pod %s failed, reason: %s", runnerPod, err.Error())
+				}
+			}(pod.Name)
+		}
+	}
+}
+
+// followRunnerLog injects the pods reported as killed in the log of the runner pod until the log ends.
+func (chaosJob *ChaosJob) followRunnerLog(ctx context.Context, jobStatusId uint, targets *chaosTargets, runnerPod string) error {
+	kube := executorFrom(ctx)
+	kubeCtx, done := kubeCall(ctx, "stream_logs", attribute.String("pod", runnerPod))
+	reader, err := kube.client.CoreV1().Pods(kube.namespace).GetLogs(runnerPod, &coreV1.PodLogOptions{
+		Follow: true,
+	}).Stream(kubeCtx)
+	done(err)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	namespace := chaosJob.Config["APP_NAMESPACE"]
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		name := killedPod(scanner.Text())
+		if name == "" {
+			continue
+		}
+		kubeCtx, done := kubeCall(ctx, "get_pod", attribute.String("pod", name))
+		pod, err := kube.client.CoreV1().Pods(namespace).Get(kubeCtx, name, metaV1.GetOptions{})
+		done(err)
+		if errors.IsNotFound(err) {
+			// gone already, the events are still recorded on its name
+			pod = &coreV1.Pod{ObjectMeta: metaV1.ObjectMeta{
+				Name:              name,
+				Namespace:         namespace,
+				DeletionTimestamp: &metaV1.Time{Time: time.Now()},
+			}}
+		} else if err != nil {
+			logFrom(ctx).WithField(TargetPodField, name).Warnf("get killed pod failed, reason: %s", err.Error())
+			continue
+		}
+		chaosJob.injectTarget(ctx, jobStatusId, targets, pod)
+	}
+	return scanner.Err()
+}
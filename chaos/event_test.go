@@ -0,0 +1,97 @@
+/*
+ *
+ *  * Licensed to the Apache Software Foundation (ASF) under one
+ *  * or more contributor license agreements.  See the NOTICE file
+ *  * distributed with this work for additional information
+ *  * regarding copyright ownership.  The ASF licenses this file
+ *  * to you under the Apache License, Version 2.0 (the
+ *  * "License"); you may not use this file except in compliance
+ *  * with the License.  You may obtain a copy of the License at
+ *  *
+ *  *     http://www.apache.org/licenses/LICENSE-2.0
+ *  *
+ *  * Unless required by applicable law or agreed to in writing, software
+ *  * distributed under the License is distributed on an "AS IS" BASIS,
+ *  * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  * See the License for the specific language governing permissions and
+ *  * limitations under the License.
+ *
+ *
+ */
+
+package chaos
+
+import (
+	"context"
+	"godzilla/db"
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"testing"
+)
+
+func TestKilledPod(t *testing.T) {
+	tests := []struct {
+		line string
+		pod  string
+	}{
+		{`time="2024-01-02T10:00:00Z" level=info msg="[Info]: Killing the following pods" PodName=nginx-7c5b6-x2x7q`, "nginx-7c5b6-x2x7q"},
+		{`{"PodName":"nginx-7c5b6-x2x7q","level":"info","msg":"[Info]: Killing the following pods"}`, "nginx-7c5b6-x2x7q"},
+		{`time="2024-01-02T10:00:00Z" level=info msg="[Info]: Target pods list, [nginx-7c5b6-x2x7q]"`, ""},
+		{`level=info msg="[Info]: Killing the following pods"`, ""},
+	}
+	for _, test := range tests {
+		if pod := killedPod(test.line); pod != test.pod {
+			t.Errorf("killedPod(%s) = %q, want %q", test.line, pod, test.pod)
+		}
+	}
+}
+
+func markedPod(name, runId string) *coreV1.Pod {
+	return &coreV1.Pod{ObjectMeta: metaV1.ObjectMeta{
+		Name:        name,
+		Namespace:   "app",
+		Labels:      map[string]string{"app": "nginx", ChaosRunLabel: runId, ChaosTypeLabel: "litmus-pod-delete"},
+		Annotations: map[string]string{ChaosRunLabel: runId, ChaosStepAnnotation: "step"},
+	}}
+}
+
+func TestSweepTargets(t *testing.T) {
+	store := db.NewMemoryStore()
+	h := NewHandler(store, nil, nil)
+	finished := &db.JobStatus{Status: runStatus(t, SuccessStatus)}
+	running := &db.JobStatus{Status: runStatus(t, RunningStatus)}
+	// a run of another replica is not in runCancels
+	elsewhere := &db.JobStatus{Status: runStatus(t, RunningStatus)}
+	for _, run := range []*db.JobStatus{finished, running, elsewhere} {
+		err := store.Runs.Add(run)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	h.runCancels.Store(running.Id, context.CancelFunc(func() {}))
+
+	client := fake.NewSimpleClientset(
+		markedPod("left-over", "1"),
+		markedPod("running", "2"),
+		markedPod("elsewhere", "3"),
+		markedPod("embedded", "4242"),
+	)
+	ctx := withExecutor(context.Background(), &executor{client: client, namespace: "chaos"})
+	h.sweepTargets(ctx)
+
+	for name, marked := range map[string]bool{"left-over": false, "running": true, "elsewhere": true, "embedded": true} {
+		pod, err := client.CoreV1().Pods("app").Get(ctx, name, metaV1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, label := pod.Labels[ChaosRunLabel]
+		_, annotation := pod.Annotations[ChaosStepAnnotation]
+		if label != marked || annotation != marked {
+			t.Errorf("pod %s has the run label %v and the step annotation %v, want %v", name, label, annotation, marked)
+		}
+		if pod.Labels["app"] != "nginx" {
+			t.Errorf("pod %s lost its own labels", name)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+/*
+ *
+ *  * Licensed to the Apache Software Foundation (ASF) under one
+ *  * or more contributor license agreements.  See the NOTICE file
+ *  * distributed with this work for additional information
+ *  * regarding copyright ownership.  The ASF licenses this file
+ *  * to you under the Apache License, Version 2.0 (the
+ *  * "License"); you may not use this file except in compliance
+ *  * with the License.  You may obtain a copy of the License at
+ *  *
+ *  *     http://www.apache.org/licenses/LICENSE-2.0
+ *  *
+ *  * Unless required by applicable law or agreed to in writing, software
+ *  * distributed under the License is distributed on an "AS IS" BASIS,
+ *  * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  * See the License for the specific language governing permissions and
+ *  * limitations under the License.
+ *
+ *
+ */
+
+package chaos
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"godzilla/env"
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"net/http"
+	"sigs.k8s.io/yaml"
+	"strconv"
+	"time"
+)
+
+const (
+	BeforePhase = "before"
+	AfterPhase  = "after"
+)
+
+func evidencePrefix(jobStatusId uint) string {
+	return fmt.Sprintf("evidence/%v/", jobStatusId)
+}
+
+// evidenceKey is evidence/<run id>/<step>/<name>
+func evidenceKey(jobStatusId uint, step, name string) string {
+	return fmt.Sprintf("%s%s/%s", evidencePrefix(jobStatusId), step, name)
+}
+
+// snapshotTargets stores the pods, the workloads owning them and the Events of the target namespace
+// of the step as yaml under <phase>/, the env values of the containers are redacted. It also runs for aborted steps, so the context is not cancelable.
+func (h *Handler) snapshotTargets(ctx context.Context, chaosJob *ChaosJob, jobStatusId uint, phase string) {
+	if !evidenceEnabled() {
+		return
+	}
+	ctx = context.WithoutCancel(ctx)
+	kube := executorFrom(ctx)
+	namespace := chaosJob.Config["APP_NAMESPACE"]
+	if namespace == "" {
+		return
+	}
+	lists := map[string]func(ctx context.Context) (any, error){
+		"pods": func(ctx context.Context) (any, error) {
+			list, err := kube.client.CoreV1().Pods(namespace).List(ctx, metaV1.ListOptions{})
+			if err == nil {
+				for i := range list.Items {
+					redactEnv(&list.Items[i].Spec)
+				}
+			}
+			return list, err
+		},
+		"deployments": func(ctx context.Context) (any, error) {
+			list, err := kube.client.AppsV1().Deployments(namespace).List(ctx, metaV1.ListOptions{})
+			if err == nil {
+				for i := range list.Items {
+					redactEnv(&list.Items[i].Spec.Template.Spec)
+				}
+			}
+			return list, err
+		},
+		"statefulsets": func(ctx context.Context) (any, error) {
+			list, err := kube.client.AppsV1().StatefulSets(namespace).List(ctx, metaV1.ListOptions{})
+			if err == nil {
+				for i := range list.Items {
+					redactEnv(&list.Items[i].Spec.Template.Spec)
+				}
+			}
+			return list, err
+		},
+		"daemonsets": func(ctx context.Context) (any, error) {
+			list, err := kube.client.AppsV1().DaemonSets(namespace).List(ctx, metaV1.ListOptions{})
+			if err == nil {
+				for i := range list.Items {
+					redactEnv(&list.Items[i].Spec.Template.Spec)
+				}
+			}
+			return list, err
+		},
+		"replicasets": func(ctx context.Context) (any, error) {
+			list, err := kube.client.AppsV1().ReplicaSets(namespace).List(ctx, metaV1.ListOptions{})
+			if err == nil {
+				for i := range list.Items {
+					redactEnv(&list.Items[i].Spec.Template.Spec)
+				}
+			}
+			return list, err
+		},
+		"events": func(ctx context.Context) (any, error) {
+			return kube.client.CoreV1().Events(namespace).List(ctx, metaV1.ListOptions{})
+		},
+	}
+	for name, list := range lists {
+		kubeCtx, done := kubeCall(ctx, "list_"+name, attribute.String("namespace", namespace))
+		objects, err := list(kubeCtx)
+		done(err)
+		if err != nil {
+			logFrom(ctx).Warnf("snapshot %s of namespace %s failed, reason: %s", name, namespace, err.Error())
+			continue
+		}
+		data, err := yaml.Marshal(objects)
+		if err != nil {
+			logFrom(ctx).Warnf("marshal %s of namespace %s failed, reason: %s", name, namespace, err.Error())
+			continue
+		}
+		err = h.artifacts.Put(ctx, evidenceKey(jobStatusId, chaosJob.Name, fmt.Sprintf("%s/%s.yaml", phase, name)),
+			bytes.NewReader(data))
+		if err != nil {
+			logFrom(ctx).Warnf("store %s snapshot of namespace %s failed, reason: %s", name, namespace, err.Error())
+		}
+	}
+}
+
+const redactedValue = "REDACTED"
+
+// redactEnv replaces the literal env values of the containers, they often hold credentials and the
+// evidence is downloadable by anyone reaching the API. The references to secrets and config maps are kept.
+func redactEnv(spec *coreV1.PodSpec) {
+	redact := func(envs []coreV1.EnvVar) {
+		for i := range envs {
+			if envs[i].Value != "" {
+				envs[i].Value = redactedValue
+			}
+		}
+	}
+	for i := range spec.InitContainers {
+		redact(spec.InitContainers[i].Env)
+	}
+	for i := range spec.Containers {
+		redact(spec.Containers[i].Env)
+	}
+	for i := range spec.EphemeralContainers {
+		redact(spec.EphemeralContainers[i].Env)
+	}
+}
+
+// captureAppLogs stores the logs written since the start of the step by the application containers
+// of the targets, i.e. the pods of APP_LABEL and TARGET_PODS. Deleted pods have no logs anymore,
+// their replacements are captured instead.
+func (h *Handler) captureAppLogs(ctx context.Context, chaosJob *ChaosJob, jobStatusId uint, start time.Time) {
+	if !evidenceEnabled() {
+		return
+	}
+	ctx = context.WithoutCancel(ctx)
+	kube := executorFrom(ctx)
+	namespace := chaosJob.Config["APP_NAMESPACE"]
+	if namespace == "" {
+		return
+	}
+	targets := make(map[string]bool)
+	for _, pod := range chaosJob.TargetPods {
+		targets[pod] = true
+	}
+	kubeCtx, done := kubeCall(ctx, "list_pods", attribute.String("namespace", namespace))
+	podList, err := kube.client.CoreV1().Pods(namespace).List(kubeCtx, metaV1.ListOptions{})
+	done(err)
+	if err != nil {
+		logFrom(ctx).Warnf("list pods of namespace %s failed, reason: %s", namespace, err.Error())
+		return
+	}
+	selector, err := labels.Parse(chaosJob.Config["APP_LABEL"])
+	if err != nil || chaosJob.Config["APP_LABEL"] == "" {
+		selector = labels.Nothing()
+	}
+	since := metaV1.NewTime(start)
+	for _, pod := range podList.Items {
+		if !targets[pod.Name] && !selector.Matches(labels.Set(pod.Labels)) {
+			continue
+		}
+		for _, container := range pod.Spec.Containers {
+			kubeCtx, done := kubeCall(ctx, "get_logs", attribute.String("pod", pod.Name))
+			data, err := kube.client.CoreV1().Pods(namespace).GetLogs(pod.Name, &coreV1.PodLogOptions{
+				Container:  container.Name,
+				SinceTime:  &since,
+				Timestamps: true,
+			}).DoRaw(kubeCtx)
+			done(err)
+			if err != nil {
+				logFrom(ctx).WithField(TargetPodField, pod.Name).
+					Warnf("get logs of container %s failed, reason: %s", container.Name, err.Error())
+				continue
+			}
+			key := evidenceKey(jobStatusId, chaosJob.Name, fmt.Sprintf("logs/%s/%s.log", pod.Name, container.Name))
+			err = h.artifacts.Put(ctx, key, bytes.NewReader(data))
+			if err != nil {
+				logFrom(ctx).WithField(TargetPodField, pod.Name).
+					Warnf("store logs of container %s failed, reason: %s", container.Name, err.Error())
+			}
+		}
+	}
+}
+
+func evidenceEnabled() bool {
+	enabled, err := strconv.ParseBool(env.EvidenceEnabled)
+	if err != nil {
+		logrus.Warnf("parse EVIDENCE_ENABLED failed, reason: %s", err.Error())
+		return false
+	}
+	return enabled
+}
+
+// DownloadEvidence sends the evidence of the run as a tar.gz archive with one directory per step.
+func (h *Handler) DownloadEvidence(c *gin.Context) {
+	id, err := idParam(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse(RequestError, err))
+		return
+	}
+	ctx := c.Request.Context()
+	prefix := evidencePrefix(uint(id))
+	keys, err := h.artifacts.List(ctx, prefix)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse(ArtifactStoreError, err))
+		return
+	}
+	if len(keys) == 0 {
+		c.AbortWithStatusJSON(http.StatusNotFound, ErrorResponse(EvidenceNotFound, nil))
+		return
+	}
+	c.Header("Content-Type", "application/gzip")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=run-%v-evidence.tar.gz", id))
+	c.Status(http.StatusOK)
+	err = writeArchive(ctx, c.Writer, h.artifacts, keys, prefix, fmt.Sprintf("run-%v/", id))
+	if err != nil {
+		// the headers are sent already, the archive is cut short
+		logFrom(ctx).WithField(RunIdField, id).Errorf("write evidence archive failed, reason: %s", err.Error())
+	}
+}
@@ -0,0 +1,55 @@
+/*
+ *
+ *  * Licensed to the Apache Software Foundation (ASF) under one
+ *  * or more contributor license agreements.  See the NOTICE file
+ *  * distributed with this work for additional information
+ *  * regarding copyright ownership.  The ASF licenses this file
+ *  * to you under the Apache License, Version 2.0 (the
+ *  * "License"); you may not use this file except in compliance
+ *  * with the License.  You may obtain a copy of the License at
+ *  *
+ *  *     http://www.apache.org/licenses/LICENSE-2.0
+ *  *
+ *  * Unless required by applicable law or agreed to in writing, software
+ *  * distributed under the License is distributed on an "AS IS" BASIS,
+ *  * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  * See the License for the specific language governing permissions and
+ *  * limitations under the License.
+ *
+ *
+ */
+package chaos
+
+import (
+	coreV1 "k8s.io/api/core/v1"
+	"reflect"
+	"testing"
+)
+
+func TestRedactEnv(t *testing.T) {
+	secretRef := &coreV1.EnvVarSource{SecretKeyRef: &coreV1.SecretKeySelector{
+		LocalObjectReference: coreV1.LocalObjectReference{Name: "db"},
+		Key:                  "password",
+	}}
+	spec := coreV1.PodSpec{
+		InitContainers: []coreV1.Container{{Name: "migrate", Env: []coreV1.EnvVar{{Name: "DB_DSN", Value: "root:pw@db"}}}},
+		Containers: []coreV1.Container{{Name: "app", Env: []coreV1.EnvVar{
+			{Name: "API_KEY", Value: "s3cret"},
+			{Name: "DB_PASSWORD", ValueFrom: secretRef},
+			{Name: "EMPTY"},
+		}}},
+	}
+	redactEnv(&spec)
+
+	if got := spec.InitContainers[0].Env; !reflect.DeepEqual(got, []coreV1.EnvVar{{Name: "DB_DSN", Value: redactedValue}}) {
+		t.Errorf("env of the init container = %+v, want the value redacted", got)
+	}
+	want := []coreV1.EnvVar{
+		{Name: "API_KEY", Value: redactedValue},
+		{Name: "DB_PASSWORD", ValueFrom: secretRef},
+		{Name: "EMPTY"},
+	}
+	if got := spec.Containers[0].Env; !reflect.DeepEqual(got, want) {
+		t.Errorf("env of the container = %+v, want %+v", got, want)
+	}
+}
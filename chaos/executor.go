@@ -0,0 +1,73 @@
+/*
+ *
+ *  * Licensed to the Apache Software Foundation (ASF) under one
+ *  * or more contributor license agreements.  See the NOTICE file
+ *  * distributed with this work for additional information
+ *  * regarding copyright ownership.  The ASF licenses this file
+ *  * to you under the Apache License, Version 2.0 (the
+ *  * "License"); you may not use this file except in compliance
+ *  * with the License.  You may obtain a copy of the License at
+ *  *
+ *  *     http://www.apache.org/licenses/LICENSE-2.0
+ *  *
+ *  * Unless required by applicable law or agreed to in writing, software
+ *  * distributed under the License is distributed on an "AS IS" BASIS,
+ *  * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  * See the License for the specific language governing permissions and
+ *  * limitations under the License.
+ *
+ *
+ */
+
+package chaos
+
+import (
+	"context"
+	"godzilla/env"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	"time"
+)
+
+// executor is what the steps of a run need from outside, the server runs them with the kube client
+// of the process and the status worker, a Runner with its own.
+type executor struct {
+	client kubernetes.Interface
+	// config of the client, only needed to exec into pods, it is nil for a fake clientset
+	config *rest.Config
+	// namespace of the chaos jobs
+	namespace string
+	// recorder may be nil, the kubernetes events are not recorded then
+	recorder record.EventRecorder
+	// report hands the status of a step over
+	report func(jobStatusId uint, chaosJob ChaosJob)
+}
+
+type executorKey struct{}
+
+func withExecutor(ctx context.Context, e *executor) context.Context {
+	return context.WithValue(ctx, executorKey{}, e)
+}
+
+// executorFrom returns the executor of the run, the one of the server if the run has none.
+func executorFrom(ctx context.Context) *executor {
+	if e, ok := ctx.Value(executorKey{}).(*executor); ok {
+		return e
+	}
+	return &executor{
+		client:    client,
+		config:    config,
+		namespace: env.JobNamespace,
+		recorder:  recorder,
+		report: func(jobStatusId uint, chaosJob ChaosJob) {
+			statusChan <- map[uint]ChaosJob{jobStatusId: chaosJob}
+		},
+	}
+}
+
+// sendStatus reports a copy of the current status of the step, the step keeps changing its config and lists.
+func (chaosJob *ChaosJob) sendStatus(ctx context.Context, jobStatusId uint) {
+	chaosJob.UpdatedAt = time.Now()
+	executorFrom(ctx).report(jobStatusId, chaosJob.copy())
+}
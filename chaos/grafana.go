@@ -0,0 +1,169 @@
+/*
+ *
+ *  * Licensed to the Apache Software Foundation (ASF) under one
+ *  * or more contributor license agreements.  See the NOTICE file
+ *  * distributed with this work for additional information
+ *  * regarding copyright ownership.  The ASF licenses this file
+ *  * to you under the Apache License, Version 2.0 (the
+ *  * "License"); you may not use this file except in compliance
+ *  * with the License.  You may obtain a copy of the License at
+ *  *
+ *  *     http://www.apache.org/licenses/LICENSE-2.0
+ *  *
+ *  * Unless required by applicable law or agreed to in writing, software
+ *  * distributed under the License is distributed on an "AS IS" BASIS,
+ *  * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  * See the License for the specific language governing permissions and
+ *  * limitations under the License.
+ *
+ *
+ */
+
+package chaos
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"godzilla/env"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// stepAnnotation is the start or the end of the fault of a step, the end closes the region opened by the start.
+type stepAnnotation struct {
+	runId    uint
+	scenario string
+	step     ChaosJob
+	end      bool
+}
+
+type grafanaAnnotation struct {
+	DashboardUID string   `json:"dashboardUID,omitempty"`
+	Time         int64    `json:"time,omitempty"`
+	TimeEnd      int64    `json:"timeEnd,omitempty"`
+	Tags         []string `json:"tags,omitempty"`
+	Text         string   `json:"text,omitempty"`
+}
+
+var annotationChan = make(chan stepAnnotation, 100)
+
+var grafanaClient = &http.Client{Timeout: 10 * time.Second}
+
+// annotateStep queues the annotation of the step if grafana is configured, it never blocks the status worker.
+func annotateStep(runId uint, scenario string, prev JobStatus, chaosJob ChaosJob) {
+	if env.GrafanaUrl == "" {
+		return
+	}
+	a := stepAnnotation{runId: runId, scenario: scenario, step: chaosJob}
+	if chaosJob.Status == RunningStatus {
+		if prev == RunningStatus {
+			return
+		}
+	} else if prev == RunningStatus {
+		a.end = true
+	} else {
+		return
+	}
+	select {
+	case annotationChan <- a:
+	default:
+		runLog(runId).WithField(StepField, chaosJob.Name).Error("grafana annotation dropped, the queue is full")
+	}
+}
+
+// AnnotationWorker creates and closes the grafana annotations in order, the ids of the open regions are kept
+// until their step ends.
+func AnnotationWorker() {
+	regions := make(map[string]int64)
+	for a := range annotationChan {
+		key := fmt.Sprintf("%v/%s", a.runId, a.step.Name)
+		if !a.end {
+			id, err := createAnnotation(a)
+			if err != nil {
+				runLog(a.runId).WithField(StepField, a.step.Name).Errorf("create grafana annotation failed, reason: %s", err.Error())
+				continue
+			}
+			regions[key] = id
+			continue
+		}
+		id, ok := regions[key]
+		if !ok {
+			continue
+		}
+		delete(regions, key)
+		err := closeAnnotation(id, a)
+		if err != nil {
+			runLog(a.runId).WithField(StepField, a.step.Name).Errorf("close grafana annotation %v failed, reason: %s", id, err.Error())
+		}
+	}
+}
+
+func annotationTags(a stepAnnotation) []string {
+	return []string{
+		"godzilla",
+		"scenario:" + a.scenario,
+		"step:" + a.step.Name,
+		"namespace:" + a.step.Config["APP_NAMESPACE"],
+	}
+}
+
+func annotationText(a stepAnnotation) string {
+	text := fmt.Sprintf("godzilla %s, run %v, scenario %s, step %s", a.step.Type, a.runId, a.scenario, a.step.Name)
+	if duration := a.step.Config["TOTAL_CHAOS_DURATION"]; duration != "" {
+		text += fmt.Sprintf(", duration %ss", duration)
+	}
+	if a.end {
+		text += fmt.Sprintf(": %s", a.step.Status)
+		if a.step.FailedReason != "" {
+			text += fmt.Sprintf(", %s", a.step.FailedReason)
+		}
+	}
+	return text
+}
+
+func createAnnotation(a stepAnnotation) (int64, error) {
+	var resp struct {
+		Id int64 `json:"id"`
+	}
+	err := callGrafana(http.MethodPost, "/api/annotations", grafanaAnnotation{
+		DashboardUID: env.GrafanaDashboardUid,
+		Time:         a.step.UpdatedAt.UnixMilli(),
+		Tags:         annotationTags(a),
+		Text:         annotationText(a),
+	}, &resp)
+	return resp.Id, err
+}
+
+func closeAnnotation(id int64, a stepAnnotation) error {
+	return callGrafana(http.MethodPatch, fmt.Sprintf("/api/annotations/%v", id), grafanaAnnotation{
+		TimeEnd: a.step.UpdatedAt.UnixMilli(),
+		Tags:    annotationTags(a),
+		Text:    annotationText(a),
+	}, nil)
+}
+
+func callGrafana(method, path string, body any, out any) error {
+	data, _ := json.Marshal(body)
+	req, err := http.NewRequest(method, strings.TrimSuffix(env.GrafanaUrl, "/")+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if env.GrafanaToken != "" {
+		req.Header.Set("Authorization", "Bearer "+env.GrafanaToken)
+	}
+	resp, err := grafanaClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,120 @@
+/*
+ *
+ *  * Licensed to the Apache Software Foundation (ASF) under one
+ *  * or more contributor license agreements.  See the NOTICE file
+ *  * distributed with this work for additional information
+ *  * regarding copyright ownership.  The ASF licenses this file
+ *  * to you under the Apache License, Version 2.0 (the
+ *  * "License"); you may not use this file except in compliance
+ *  * with the License.  You may obtain a copy of the License at
+ *  *
+ *  *     http://www.apache.org/licenses/LICENSE-2.0
+ *  *
+ *  * Unless required by applicable law or agreed to in writing, software
+ *  * distributed under the License is distributed on an "AS IS" BASIS,
+ *  * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  * See the License for the specific language governing permissions and
+ *  * limitations under the License.
+ *
+ *
+ */
+package chaos
+
+import (
+	"encoding/json"
+	"godzilla/env"
+	"godzilla/types"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// annotationWorker is started once, the regions of the worker are lost if another one reads the queue
+var annotationWorker sync.Once
+
+type grafanaCall struct {
+	method, path, auth string
+	annotation         grafanaAnnotation
+}
+
+func TestAnnotationWorker(t *testing.T) {
+	calls := make(chan grafanaCall, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call := grafanaCall{method: r.Method, path: r.URL.Path, auth: r.Header.Get("Authorization")}
+		if err := json.NewDecoder(r.Body).Decode(&call.annotation); err != nil {
+			t.Errorf("decode annotation failed, reason: %s", err.Error())
+		}
+		calls <- call
+		if r.Method == http.MethodPost {
+			_, _ = w.Write([]byte(`{"id":7,"message":"Annotation added"}`))
+		}
+	}))
+	defer server.Close()
+	url, token, uid := env.GrafanaUrl, env.GrafanaToken, env.GrafanaDashboardUid
+	env.GrafanaUrl, env.GrafanaToken, env.GrafanaDashboardUid = server.URL+"/", "token", "dashboard"
+	defer func() { env.GrafanaUrl, env.GrafanaToken, env.GrafanaDashboardUid = url, token, uid }()
+	annotationWorker.Do(func() { go AnnotationWorker() })
+
+	start := time.Now()
+	step := ChaosJob{
+		Name:   "kill-nginx",
+		Type:   string(types.LitmusPodDelete),
+		Status: RunningStatus,
+		Config: map[string]string{"APP_NAMESPACE": "default", "TOTAL_CHAOS_DURATION": "30"},
+	}
+	step.UpdatedAt = start
+	annotateStep(1, "nginx", PendingStatus, step)
+	// still running, nothing to annotate
+	annotateStep(1, "nginx", RunningStatus, step)
+	step.Status, step.FailedReason, step.UpdatedAt = FailedStatus, "pods not ready", start.Add(time.Minute)
+	annotateStep(1, "nginx", RunningStatus, step)
+
+	tags := []string{"godzilla", "scenario:nginx", "step:kill-nginx", "namespace:default"}
+	want := []grafanaCall{
+		{method: http.MethodPost, path: "/api/annotations", auth: "Bearer token", annotation: grafanaAnnotation{
+			DashboardUID: "dashboard",
+			Time:         start.UnixMilli(),
+			Tags:         tags,
+			Text:         "godzilla litmus-pod-delete, run 1, scenario nginx, step kill-nginx, duration 30s",
+		}},
+		{method: http.MethodPatch, path: "/api/annotations/7", auth: "Bearer token", annotation: grafanaAnnotation{
+			TimeEnd: start.Add(time.Minute).UnixMilli(),
+			Tags:    tags,
+			Text:    "godzilla litmus-pod-delete, run 1, scenario nginx, step kill-nginx, duration 30s: failed, pods not ready",
+		}},
+	}
+	for i := range want {
+		select {
+		case call := <-calls:
+			if !reflect.DeepEqual(call, want[i]) {
+				t.Errorf("call %d = %+v, want %+v", i, call, want[i])
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("call %d to grafana missing", i)
+		}
+	}
+	select {
+	case call := <-calls:
+		t.Errorf("unexpected call %+v", call)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestCallGrafanaStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+	url := env.GrafanaUrl
+	env.GrafanaUrl = server.URL
+	defer func() { env.GrafanaUrl = url }()
+
+	err := callGrafana(http.MethodPost, "/api/annotations", grafanaAnnotation{}, nil)
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("callGrafana() = %v, want the status code error", err)
+	}
+}
@@ -25,7 +25,7 @@ import (
 	"context"
 	"fmt"
 	"github.com/sirupsen/logrus"
-	"godzilla/env"
+	"go.opentelemetry.io/otel/attribute"
 	"godzilla/utils"
 	batchV1 "k8s.io/api/batch/v1"
 	coreV1 "k8s.io/api/core/v1"
@@ -37,7 +37,7 @@ import (
 	"time"
 )
 
-func (chaosJob *ChaosJob) LitmusJob(jobStatusId uint) batchV1.Job {
+func (chaosJob *ChaosJob) LitmusJob(namespace string, jobStatusId uint) batchV1.Job {
 	var (
 		backOffLimit int32 = 0
 		envs         []coreV1.EnvVar
@@ -57,11 +57,13 @@ func (chaosJob *ChaosJob) LitmusJob(jobStatusId uint) batchV1.Job {
 	job := batchV1.Job{
 		ObjectMeta: metaV1.ObjectMeta{
 			Name:      jobName,
-			Namespace: env.JobNamespace,
+			Namespace: namespace,
 			Labels: map[string]string{
-				"chaos.job":      "true",
-				"chaos.job.id":   fmt.Sprintf("%v", jobStatusId),
-				"chaos.job.name": chaosJob.Name,
+				"chaos.job":           "true",
+				"chaos.job.id":        fmt.Sprintf("%v", jobStatusId),
+				"chaos.job.name":      chaosJob.Name,
+				"chaos.job.type":      chaosJob.Type,
+				"chaos.job.namespace": chaosJob.Config["APP_NAMESPACE"],
 			},
 		},
 		Spec: batchV1.JobSpec{
@@ -98,7 +100,7 @@ func (chaosJob *ChaosJob) LitmusJob(jobStatusId uint) batchV1.Job {
 	return job
 }
 
-func (chaosJob *ChaosJob) LitmusJobStress(jobStatusId uint, nodeName, podName string) batchV1.Job {
+func (chaosJob *ChaosJob) LitmusJobStress(namespace string, jobStatusId uint, nodeName, podName string) batchV1.Job {
 	var (
 		backOffLimit int32 = 0
 		envs         []coreV1.EnvVar
@@ -120,12 +122,14 @@ func (chaosJob *ChaosJob) LitmusJobStress(jobStatusId uint, nodeName, podName st
 	job := batchV1.Job{
 		ObjectMeta: metaV1.ObjectMeta{
 			Name:      jobName,
-			Namespace: env.JobNamespace,
+			Namespace: namespace,
 			Labels: map[string]string{
-				"chaos.job":      "true",
-				"chaos.job.id":   fmt.Sprintf("%v", jobStatusId),
-				"chaos.job.name": chaosJob.Name,
-				"chaos.job.pod":  podName,
+				"chaos.job":           "true",
+				"chaos.job.id":        fmt.Sprintf("%v", jobStatusId),
+				"chaos.job.name":      chaosJob.Name,
+				"chaos.job.pod":       podName,
+				"chaos.job.type":      chaosJob.Type,
+				"chaos.job.namespace": chaosJob.Config["APP_NAMESPACE"],
 			},
 		},
 		Spec: batchV1.JobSpec{
@@ -200,53 +204,79 @@ func (chaosJob *ChaosJob) LitmusJobStress(jobStatusId uint, nodeName, podName st
 	return job
 }
 
-func runLitmusCommon(chaosJob *ChaosJob, jobStatusId uint) {
-	job := chaosJob.LitmusJob(jobStatusId)
-	logrus.Infof("creating job %s, run id %v", chaosJob.Name, jobStatusId)
+func runLitmusCommon(ctx context.Context, chaosJob *ChaosJob, jobStatusId uint) {
+	kube := executorFrom(ctx)
+	job := chaosJob.LitmusJob(kube.namespace, jobStatusId)
+	logger := logFrom(ctx).WithField(JobNameField, job.Name)
+	logger.Info("creating job")
 	start := time.Now().Unix()
 	duration, _ := strconv.Atoi(chaosJob.Config["TOTAL_CHAOS_DURATION"])
 	elapsed := int(start) + duration
-	_, err := client.BatchV1().Jobs(env.JobNamespace).Create(context.TODO(), &job, metaV1.CreateOptions{})
+	kubeCtx, done := kubeCall(ctx, "create_job", attribute.String("job", job.Name))
+	_, err := kube.client.BatchV1().Jobs(kube.namespace).Create(kubeCtx, &job, metaV1.CreateOptions{})
+	done(err)
 	if err != nil {
-		logrus.Errorf("job %s run failed, reason: %s", chaosJob.Name, err.Error())
+		logger.Errorf("create job failed, reason: %s", err.Error())
 		chaosJob.Status = FailedStatus
 		chaosJob.FailedReason = err.Error()
-		statusChan <- map[uint]ChaosJob{jobStatusId: *chaosJob}
+		chaosJob.sendStatus(ctx, jobStatusId)
 		return
 	}
-	logrus.Infof("job %s created, run id %v", chaosJob.Name, jobStatusId)
+	logger.Info("job created")
+	chaosJob.JobNames = append(chaosJob.JobNames, job.Name)
+	chaosJobsActive.WithLabelValues(chaosJob.Type, chaosJob.Config["APP_NAMESPACE"]).Inc()
+	targets := newChaosTargets()
+	watchCtx, stopWatch := context.WithCancel(ctx)
+	followed := make(chan struct{})
+	go func() {
+		defer close(followed)
+		chaosJob.followTargets(watchCtx, jobStatusId, targets)
+	}()
+	defer func() {
+		// no target may be marked after the revert
+		stopWatch()
+		<-followed
+		chaosJob.revertTargets(ctx, jobStatusId, targets)
+	}()
+	if chaosJob.Config["TARGET_PODS"] != "" {
+		for _, targetPod := range strings.Split(chaosJob.Config["TARGET_PODS"], ",") {
+			chaosJob.TargetPods = append(chaosJob.TargetPods, strings.TrimSpace(targetPod))
+		}
+	}
 	// job status started
 	chaosJob.Status = RunningStatus
-	statusChan <- map[uint]ChaosJob{jobStatusId: *chaosJob}
+	chaosJob.sendStatus(ctx, jobStatusId)
 	// watch for the status
-	w, err := client.CoreV1().Pods(env.JobNamespace).Watch(context.TODO(), metaV1.ListOptions{
+	kubeCtx, done = kubeCall(ctx, "watch_pods")
+	w, err := kube.client.CoreV1().Pods(kube.namespace).Watch(kubeCtx, metaV1.ListOptions{
 		LabelSelector: fmt.Sprintf("chaos.job.id=%v,chaos.job.name=%s", jobStatusId, chaosJob.Name),
 	})
+	done(err)
 	if err != nil {
-		logrus.Errorf("job %s status watch failed, reason: %s", chaosJob.Name, err.Error())
+		logger.Errorf("job status watch failed, reason: %s", err.Error())
 		chaosJob.Status = FailedStatus
 		chaosJob.FailedReason = err.Error()
-		statusChan <- map[uint]ChaosJob{jobStatusId: *chaosJob}
+		chaosJob.sendStatus(ctx, jobStatusId)
 		return
 	}
-	logrus.Infof("watching for job %s, run id %v", chaosJob.Name, jobStatusId)
+	logger.Info("watching for job")
 	for event := range w.ResultChan() {
 		if event.Object != nil {
 			if reflect.ValueOf(event.Object).Type().Elem().Name() == "Pod" {
 				podObject := event.Object.(*coreV1.Pod)
 				if podObject.Status.Phase == coreV1.PodSucceeded || podObject.Status.Phase == coreV1.PodFailed {
 					// cleanup
-					logrus.Infof("job %s finished, run id %v, starting cleanup", chaosJob.Name, jobStatusId)
-					err = chaosJob.cleanJob(jobStatusId)
+					logger.Info("job finished, starting cleanup")
+					err = chaosJob.cleanJob(ctx, jobStatusId)
 					if err != nil {
-						logrus.Errorf("job %s cleanup failed, reason: %s", chaosJob.Name, err.Error())
+						logger.Errorf("job cleanup failed, reason: %s", err.Error())
 						chaosJob.Status = FailedStatus
 						chaosJob.FailedReason = err.Error()
-						statusChan <- map[uint]ChaosJob{jobStatusId: *chaosJob}
+						chaosJob.sendStatus(ctx, jobStatusId)
 						w.Stop()
 						return
 					}
-					logrus.Infof("job %s cleanup done, run id %v", chaosJob.Name, jobStatusId)
+					logger.Info("job cleanup done")
 					switch podObject.Status.Phase {
 					case coreV1.PodSucceeded:
 						chaosJob.Status = SuccessStatus
@@ -256,7 +286,7 @@ func runLitmusCommon(chaosJob *ChaosJob, jobStatusId uint) {
 					default:
 						chaosJob.Status = UnknownStatus
 					}
-					statusChan <- map[uint]ChaosJob{jobStatusId: *chaosJob}
+					chaosJob.sendStatus(ctx, jobStatusId)
 					w.Stop()
 					break
 				} else {
@@ -264,9 +294,9 @@ func runLitmusCommon(chaosJob *ChaosJob, jobStatusId uint) {
 					if elapsed+120 < int(time.Now().Unix()) {
 						chaosJob.Status = FailedStatus
 						chaosJob.FailedReason = "chaos job pod not started"
-						statusChan <- map[uint]ChaosJob{jobStatusId: *chaosJob}
-						logrus.Infof("job %s failed, run id %v, starting cleanup", chaosJob.Name, jobStatusId)
-						chaosJob.cleanJob(jobStatusId)
+						chaosJob.sendStatus(ctx, jobStatusId)
+						logger.Info("job failed, starting cleanup")
+						chaosJob.cleanJob(ctx, jobStatusId)
 						w.Stop()
 						break
 					}
@@ -274,124 +304,177 @@ func runLitmusCommon(chaosJob *ChaosJob, jobStatusId uint) {
 			}
 		}
 	}
+	// the watch is closed by the abort of the run
+	if ctx.Err() != nil && chaosJob.Status == RunningStatus {
+		logger.Info("job aborted, starting cleanup")
+		err = chaosJob.cleanJob(context.WithoutCancel(ctx), jobStatusId)
+		if err != nil {
+			logger.Errorf("job cleanup failed, reason: %s", err.Error())
+		}
+		chaosJob.Status = AbortedStatus
+		chaosJob.FailedReason = abortedReason
+		chaosJob.sendStatus(ctx, jobStatusId)
+	}
 }
 
-func runLitmusStress(chaosJob *ChaosJob, jobStatusId uint) {
-	var job batchV1.Job
-	start := time.Now().Unix()
-	duration, _ := strconv.Atoi(chaosJob.Config["TOTAL_CHAOS_DURATION"])
-	elapsed := int(start) + duration
-	go func() {
-		logrus.Infof("creating job %s, run id %v", chaosJob.Name, jobStatusId)
-		var targetPods []string
-		if chaosJob.Config["TARGET_PODS"] != "" {
-			targetPods = strings.Split(chaosJob.Config["TARGET_PODS"], ",")
-		}
-		var percentage int
-		if chaosJob.Config["PODS_AFFECTED_PERC"] == "" {
-			percentage = 0
-		} else {
-			percentage, _ = strconv.Atoi(chaosJob.Config["PODS_AFFECTED_PERC"])
-		}
+// affectedPercentage is PODS_AFFECTED_PERC clamped to 0-100, zero if it is not set.
+func affectedPercentage(chaosJob *ChaosJob) int {
+	percentage, _ := strconv.Atoi(chaosJob.Config["PODS_AFFECTED_PERC"])
+	return min(max(percentage, 0), 100)
+}
 
-		var (
-			pods    []coreV1.Pod
-			allPods []coreV1.Pod
-		)
+// stressTargets picks the pods of a pod-io-stress step: the TARGET_PODS if set, otherwise PODS_AFFECTED_PERC
+// percent of the ready pods of APP_LABEL, one pod if the percentage is not set. allPods are all ready pods
+// of APP_LABEL.
+func stressTargets(ctx context.Context, chaosJob *ChaosJob) (pods, allPods []coreV1.Pod, err error) {
+	kube := executorFrom(ctx)
+	logger := logFrom(ctx)
+	var targetPods []string
+	if chaosJob.Config["TARGET_PODS"] != "" {
+		targetPods = strings.Split(chaosJob.Config["TARGET_PODS"], ",")
+	}
+	percentage := affectedPercentage(chaosJob)
 
-		// if TARGET_PODS is not empty, use it
-		if len(targetPods) > 0 {
-			for _, targetPod := range targetPods {
-				targetPod = strings.TrimSpace(targetPod)
-				podObject, err := client.CoreV1().Pods(chaosJob.Config["APP_NAMESPACE"]).Get(context.TODO(), targetPod, metaV1.GetOptions{})
-				if err != nil {
-					chaosJob.Status = FailedStatus
-					chaosJob.FailedReason = err.Error()
-					statusChan <- map[uint]ChaosJob{jobStatusId: *chaosJob}
-					return
-				}
-				pods = append(pods, *podObject)
-			}
-		} else {
-			// check label
-			podList, err := client.CoreV1().Pods(chaosJob.Config["APP_NAMESPACE"]).List(context.TODO(), metaV1.ListOptions{
-				LabelSelector: chaosJob.Config["APP_LABEL"],
-				FieldSelector: "status.phase=Running",
-			})
+	// if TARGET_PODS is not empty, use it
+	if len(targetPods) > 0 {
+		for _, targetPod := range targetPods {
+			targetPod = strings.TrimSpace(targetPod)
+			kubeCtx, done := kubeCall(ctx, "get_pod", attribute.String("pod", targetPod))
+			podObject, err := kube.client.CoreV1().Pods(chaosJob.Config["APP_NAMESPACE"]).Get(kubeCtx, targetPod, metaV1.GetOptions{})
+			done(err)
 			if err != nil {
-				chaosJob.Status = FailedStatus
-				chaosJob.FailedReason = err.Error()
-				statusChan <- map[uint]ChaosJob{jobStatusId: *chaosJob}
-				return
+				return nil, nil, err
 			}
-			for i := range podList.Items {
-				for _, condition := range podList.Items[i].Status.Conditions {
-					if condition.Type == coreV1.ContainersReady && condition.Status == coreV1.ConditionTrue {
-						if podList.Items[i].ObjectMeta.DeletionTimestamp == nil {
-							allPods = append(allPods, podList.Items[i])
-							pods = append(pods, podList.Items[i])
-						}
+			pods = append(pods, *podObject)
+		}
+	} else {
+		// check label
+		kubeCtx, done := kubeCall(ctx, "list_pods")
+		podList, err := kube.client.CoreV1().Pods(chaosJob.Config["APP_NAMESPACE"]).List(kubeCtx, metaV1.ListOptions{
+			LabelSelector: chaosJob.Config["APP_LABEL"],
+			FieldSelector: "status.phase=Running",
+		})
+		done(err)
+		if err != nil {
+			return nil, nil, err
+		}
+		for i := range podList.Items {
+			for _, condition := range podList.Items[i].Status.Conditions {
+				if condition.Type == coreV1.ContainersReady && condition.Status == coreV1.ConditionTrue {
+					if podList.Items[i].ObjectMeta.DeletionTimestamp == nil {
+						allPods = append(allPods, podList.Items[i])
+						pods = append(pods, podList.Items[i])
 					}
 				}
 			}
 		}
-		logrus.Infof("the total running pods is %d", len(allPods))
-		// set the pods as percentage
-		if percentage == 0 {
-			pods = pods[:1]
-		} else {
-			pods = pods[:len(pods)*percentage/100]
+	}
+	logger.Infof("the total running pods is %d", len(allPods))
+	// set the pods as percentage
+	if percentage == 0 {
+		pods = pods[:min(len(pods), 1)]
+	} else {
+		pods = pods[:len(pods)*percentage/100]
+	}
+	return pods, allPods, nil
+}
+
+// stressConfig sets the config of the pod-io-stress chaos job of the target pod.
+func (chaosJob *ChaosJob) stressConfig(pod *coreV1.Pod) {
+	chaosJob.Config["APP_POD"] = pod.Name
+	chaosJob.Config["CPU_CORES"] = "0"
+	if chaosJob.Config["APP_CONTAINER"] == "" {
+		chaosJob.Config["APP_CONTAINER"] = pod.Spec.Containers[0].Name
+	}
+	chaosJob.Config["FILESYSTEM_UTILIZATION_PERCENTAGE"] = "0"
+	chaosJob.Config["STRESS_TYPE"] = "pod-io-stress"
+}
+
+func runLitmusStress(ctx context.Context, chaosJob *ChaosJob, jobStatusId uint) {
+	var job batchV1.Job
+	kube := executorFrom(ctx)
+	targets := newChaosTargets()
+	start := time.Now().Unix()
+	duration, _ := strconv.Atoi(chaosJob.Config["TOTAL_CHAOS_DURATION"])
+	elapsed := int(start) + duration
+	logger := logFrom(ctx)
+	// the watch changes the step until it is stopped, the cleanup waits for it
+	watchCtx, stopWatch := context.WithCancel(ctx)
+	watchDone := make(chan struct{})
+	failed := make(chan struct{})
+	fail := func(err error) {
+		// the calls cut short by stopping the watch are not a failure of the step
+		if watchCtx.Err() != nil {
+			return
+		}
+		chaosJob.Status = FailedStatus
+		chaosJob.FailedReason = err.Error()
+		chaosJob.sendStatus(ctx, jobStatusId)
+		close(failed)
+	}
+	go func() {
+		defer close(watchDone)
+		ctx := watchCtx
+		logger.Info("creating jobs")
+		percentage := affectedPercentage(chaosJob)
+		pods, allPods, err := stressTargets(ctx, chaosJob)
+		if err != nil {
+			fail(err)
+			return
 		}
 		var podNames []string
 		for i := range pods {
 			podNames = append(podNames, pods[i].Name)
 		}
-		logrus.Infof("the target pods are %v", podNames)
+		logger.Infof("the target pods are %v", podNames)
+		chaosJob.TargetPods = podNames
 
 		for _, podObject := range pods {
 			if podObject.Status.Phase == coreV1.PodRunning {
 				// need to fetch the target node name
 				nodeName := podObject.Spec.NodeName
 				podName := podObject.Name
-				chaosJob.Config["APP_POD"] = podName
-				chaosJob.Config["CPU_CORES"] = "0"
-				if chaosJob.Config["APP_CONTAINER"] == "" {
-					chaosJob.Config["APP_CONTAINER"] = podObject.Spec.Containers[0].Name
-				}
-				chaosJob.Config["FILESYSTEM_UTILIZATION_PERCENTAGE"] = "0"
-				chaosJob.Config["STRESS_TYPE"] = "pod-io-stress"
-				job = chaosJob.LitmusJobStress(jobStatusId, nodeName, podName)
-				_, err := client.BatchV1().Jobs(env.JobNamespace).Create(context.TODO(), &job, metaV1.CreateOptions{})
+				chaosJob.stressConfig(&podObject)
+				job = chaosJob.LitmusJobStress(kube.namespace, jobStatusId, nodeName, podName)
+				kubeCtx, done := kubeCall(ctx, "create_job", attribute.String("job", job.Name))
+				_, err := kube.client.BatchV1().Jobs(kube.namespace).Create(kubeCtx, &job, metaV1.CreateOptions{})
+				done(err)
 				if err != nil {
-					chaosJob.Status = FailedStatus
-					chaosJob.FailedReason = err.Error()
-					statusChan <- map[uint]ChaosJob{jobStatusId: *chaosJob}
+					fail(err)
 					return
 				}
+				chaosJob.JobNames = append(chaosJob.JobNames, job.Name)
+				logger.WithFields(logrus.Fields{JobNameField: job.Name, TargetPodField: podName}).Info("job created")
+				chaosJob.injectTarget(ctx, jobStatusId, targets, &podObject)
+				chaosJobsActive.WithLabelValues(chaosJob.Type, chaosJob.Config["APP_NAMESPACE"]).Inc()
 			}
 		}
-		logrus.Infof("job %s created, run id %v", chaosJob.Name, jobStatusId)
+		logger.Info("jobs created")
 
 		// job status started
 		chaosJob.Status = RunningStatus
-		statusChan <- map[uint]ChaosJob{jobStatusId: *chaosJob}
+		chaosJob.sendStatus(ctx, jobStatusId)
 
 		// only label pods needs to be watched
-		w, err := client.CoreV1().Pods(chaosJob.Config["APP_NAMESPACE"]).Watch(context.TODO(), metaV1.ListOptions{
+		kubeCtx, done := kubeCall(ctx, "watch_pods")
+		w, err := kube.client.CoreV1().Pods(chaosJob.Config["APP_NAMESPACE"]).Watch(kubeCtx, metaV1.ListOptions{
 			LabelSelector: chaosJob.Config["APP_LABEL"],
 		})
+		done(err)
 		if err != nil {
-			chaosJob.Status = FailedStatus
-			chaosJob.FailedReason = err.Error()
-			statusChan <- map[uint]ChaosJob{jobStatusId: *chaosJob}
+			fail(err)
 			return
 		}
-		logrus.Infof("watching for job %s, run id %v", chaosJob.Name, jobStatusId)
+		logger.Info("watching for target pods")
+		go func() {
+			<-ctx.Done()
+			w.Stop()
+		}()
 
 		for event := range w.ResultChan() {
 			// check timeout
 			if elapsed < int(time.Now().Unix()) {
-				logrus.Infof("watch for job %s ended, run id %v", chaosJob.Name, jobStatusId)
+				logger.Info("watch for target pods ended")
 				w.Stop()
 				return
 			}
@@ -407,32 +490,28 @@ func runLitmusStress(chaosJob *ChaosJob, jobStatusId uint) {
 									if podObject.ObjectMeta.DeletionTimestamp == nil {
 										// detect newly ready pod
 										// if the pod is in the list, just start a new chaos pod
-										logrus.Infof("new running pod %s detected, job %s, run id %v",
-											podObject.Name, chaosJob.Name, jobStatusId)
+										logger.WithField(TargetPodField, podObject.Name).Info("new running pod detected")
 										for _, p := range pods {
 											if p.Name == podObject.Name {
 												if elapsed > int(time.Now().Unix()) {
-													logrus.Infof("scheduling new chaos job for pod %s, job %s, run id %v",
-														podObject.Name, chaosJob.Name, jobStatusId)
+													logger.WithField(TargetPodField, podObject.Name).Info("scheduling new chaos job for pod")
 													nodeName := podObject.Spec.NodeName
 													podName := podObject.Name
-													chaosJob.Config["APP_POD"] = podName
-													chaosJob.Config["CPU_CORES"] = "0"
-													if chaosJob.Config["APP_CONTAINER"] == "" {
-														chaosJob.Config["APP_CONTAINER"] = podObject.Spec.Containers[0].Name
-													}
+													chaosJob.stressConfig(podObject)
 													chaosJob.Config["TOTAL_CHAOS_DURATION"] = fmt.Sprintf("%v", elapsed-int(time.Now().Unix()))
-													chaosJob.Config["FILESYSTEM_UTILIZATION_PERCENTAGE"] = "0"
-													chaosJob.Config["STRESS_TYPE"] = "pod-io-stress"
-													job = chaosJob.LitmusJobStress(jobStatusId, nodeName, podName)
-													_, err := client.BatchV1().Jobs(env.JobNamespace).Create(context.TODO(), &job, metaV1.CreateOptions{})
+													job = chaosJob.LitmusJobStress(kube.namespace, jobStatusId, nodeName, podName)
+													kubeCtx, done := kubeCall(ctx, "create_job", attribute.String("job", job.Name))
+													_, err := kube.client.BatchV1().Jobs(kube.namespace).Create(kubeCtx, &job, metaV1.CreateOptions{})
+													done(err)
 													if err != nil {
-														chaosJob.Status = FailedStatus
-														chaosJob.FailedReason = err.Error()
-														statusChan <- map[uint]ChaosJob{jobStatusId: *chaosJob}
+														fail(err)
 														w.Stop()
 														return
 													}
+													chaosJob.JobNames = append(chaosJob.JobNames, job.Name)
+													logger.WithFields(logrus.Fields{JobNameField: job.Name, TargetPodField: podName}).Info("job created")
+													chaosJob.injectTarget(ctx, jobStatusId, targets, podObject)
+													chaosJobsActive.WithLabelValues(chaosJob.Type, chaosJob.Config["APP_NAMESPACE"]).Inc()
 													break
 												}
 											}
@@ -454,29 +533,28 @@ func runLitmusStress(chaosJob *ChaosJob, jobStatusId uint) {
 												expected = len(allPods) * percentage / 100
 											}
 											if expected > len(pods) && elapsed > int(time.Now().Unix()) {
-												logrus.Infof("need to add a new job for the increment, scheduling new chaos job for pod %s, job %s, run id %v",
-													podObject.Name, chaosJob.Name, jobStatusId)
+												logger.WithField(TargetPodField, podObject.Name).
+													Info("need to add a new job for the increment, scheduling new chaos job for pod")
 												// need to scale up
 												nodeName := podObject.Spec.NodeName
 												podName := podObject.Name
-												chaosJob.Config["APP_POD"] = podName
-												chaosJob.Config["CPU_CORES"] = "0"
-												if chaosJob.Config["APP_CONTAINER"] == "" {
-													chaosJob.Config["APP_CONTAINER"] = podObject.Spec.Containers[0].Name
-												}
+												chaosJob.stressConfig(podObject)
 												chaosJob.Config["TOTAL_CHAOS_DURATION"] = fmt.Sprintf("%v", elapsed-int(time.Now().Unix()))
-												chaosJob.Config["FILESYSTEM_UTILIZATION_PERCENTAGE"] = "0"
-												chaosJob.Config["STRESS_TYPE"] = "pod-io-stress"
-												job = chaosJob.LitmusJobStress(jobStatusId, nodeName, podName)
-												_, err := client.BatchV1().Jobs(env.JobNamespace).Create(context.TODO(), &job, metaV1.CreateOptions{})
+												job = chaosJob.LitmusJobStress(kube.namespace, jobStatusId, nodeName, podName)
+												kubeCtx, done := kubeCall(ctx, "create_job", attribute.String("job", job.Name))
+												_, err := kube.client.BatchV1().Jobs(kube.namespace).Create(kubeCtx, &job, metaV1.CreateOptions{})
+												done(err)
 												if err != nil {
-													chaosJob.Status = FailedStatus
-													chaosJob.FailedReason = err.Error()
-													statusChan <- map[uint]ChaosJob{jobStatusId: *chaosJob}
+													fail(err)
 													w.Stop()
 													return
 												}
 												pods = append(pods, *podObject)
+												chaosJob.JobNames = append(chaosJob.JobNames, job.Name)
+												logger.WithFields(logrus.Fields{JobNameField: job.Name, TargetPodField: podName}).Info("job created")
+												chaosJob.injectTarget(ctx, jobStatusId, targets, podObject)
+												chaosJobsActive.WithLabelValues(chaosJob.Type, chaosJob.Config["APP_NAMESPACE"]).Inc()
+												chaosJob.TargetPods = append(chaosJob.TargetPods, podName)
 											}
 										}
 									}
@@ -487,8 +565,7 @@ func runLitmusStress(chaosJob *ChaosJob, jobStatusId uint) {
 						// detect the deleted pod
 						for i := range pods {
 							if pods[i].Name == podObject.Name {
-								logrus.Infof("new added pod %s detected, job %s, run id %v",
-									podObject.Name, chaosJob.Name, jobStatusId)
+								logger.WithField(TargetPodField, podObject.Name).Info("deleted target pod detected")
 								// remove from the pods list
 								pods = append(pods[:i], pods[i+1:]...)
 								break
@@ -496,8 +573,7 @@ func runLitmusStress(chaosJob *ChaosJob, jobStatusId uint) {
 						}
 						for i := range allPods {
 							if allPods[i].Name == podObject.Name {
-								logrus.Infof("remove %s from the allPods, job %s, run id %v",
-									podObject.Name, chaosJob.Name, jobStatusId)
+								logger.WithField(TargetPodField, podObject.Name).Info("remove pod from the allPods")
 								// remove from the pods list
 								allPods = append(allPods[:i], allPods[i+1:]...)
 								break
@@ -509,19 +585,40 @@ func runLitmusStress(chaosJob *ChaosJob, jobStatusId uint) {
 		}
 	}()
 	// todo maybe this is not very schoen
-	time.Sleep(time.Duration(duration) * time.Second)
+	select {
+	case <-time.After(time.Duration(duration) * time.Second):
+	case <-ctx.Done():
+		logger.Info("jobs aborted")
+	case <-failed:
+	}
+	stopWatch()
+	<-watchDone
 	// need cleanup here
-	logrus.Infof("job %s finished, run id %v, starting cleanup", chaosJob.Name, jobStatusId)
-	err := chaosJob.cleanJob(jobStatusId)
+	logger.Info("jobs finished, starting cleanup")
+	cleanCtx := context.WithoutCancel(ctx)
+	defer chaosJob.revertTargets(cleanCtx, jobStatusId, targets)
+	err := chaosJob.cleanJob(cleanCtx, jobStatusId)
 	if err != nil {
-		logrus.Errorf("job %s cleanup failed, reason: %s", chaosJob.Name, err.Error())
+		logger.Errorf("jobs cleanup failed, reason: %s", err.Error())
 		chaosJob.Status = FailedStatus
 		chaosJob.FailedReason = err.Error()
-		statusChan <- map[uint]ChaosJob{jobStatusId: *chaosJob}
+		chaosJob.sendStatus(ctx, jobStatusId)
+		return
+	}
+	logger.Info("jobs cleanup done")
+	select {
+	case <-failed:
+		// the failure is reported already
+		return
+	default:
+	}
+	if ctx.Err() != nil {
+		chaosJob.Status = AbortedStatus
+		chaosJob.FailedReason = abortedReason
+		chaosJob.sendStatus(ctx, jobStatusId)
 		return
 	}
-	logrus.Infof("job %s cleanup done, run id %v", chaosJob.Name, jobStatusId)
 	// set status to success
 	chaosJob.Status = SuccessStatus
-	statusChan <- map[uint]ChaosJob{jobStatusId: *chaosJob}
+	chaosJob.sendStatus(ctx, jobStatusId)
 }
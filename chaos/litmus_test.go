@@ -0,0 +1,139 @@
+/*
+ *
+ *  * Licensed to the Apache Software Foundation (ASF) under one
+ *  * or more contributor license agreements.  See the NOTICE file
+ *  * distributed with this work for additional information
+ *  * regarding copyright ownership.  The ASF licenses this file
+ *  * to you under the Apache License, Version 2.0 (the
+ *  * "License"); you may not use this file except in compliance
+ *  * with the License.  You may obtain a copy of the License at
+ *  *
+ *  *     http://www.apache.org/licenses/LICENSE-2.0
+ *  *
+ *  * Unless required by applicable law or agreed to in writing, software
+ *  * distributed under the License is distributed on an "AS IS" BASIS,
+ *  * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  * See the License for the specific language governing permissions and
+ *  * limitations under the License.
+ *
+ *
+ */
+
+package chaos
+
+import (
+	"context"
+	"fmt"
+	"godzilla/types"
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"sync"
+	"testing"
+	"time"
+)
+
+func readyPod(name string) *coreV1.Pod {
+	return &coreV1.Pod{
+		ObjectMeta: metaV1.ObjectMeta{Name: name, Namespace: "app", Labels: map[string]string{"app": "nginx"}},
+		Spec:       coreV1.PodSpec{NodeName: "node", Containers: []coreV1.Container{{Name: "nginx"}}},
+		Status: coreV1.PodStatus{
+			Phase:      coreV1.PodRunning,
+			Conditions: []coreV1.PodCondition{{Type: coreV1.ContainersReady, Status: coreV1.ConditionTrue}},
+		},
+	}
+}
+
+// statusRecorder keeps the statuses reported by the steps.
+type statusRecorder struct {
+	mu       sync.Mutex
+	statuses []ChaosJob
+}
+
+func (r *statusRecorder) report(jobStatusId uint, chaosJob ChaosJob) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.statuses = append(r.statuses, chaosJob)
+}
+
+func (r *statusRecorder) last() ChaosJob {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.statuses) == 0 {
+		return ChaosJob{}
+	}
+	return r.statuses[len(r.statuses)-1]
+}
+
+// TestRunLitmusStressAbort aborts a step while its watch schedules chaos jobs for the pods becoming
+// ready again, run it with -race.
+func TestRunLitmusStressAbort(t *testing.T) {
+	client := fake.NewSimpleClientset(readyPod("nginx-1"), readyPod("nginx-2"))
+	statuses := &statusRecorder{}
+	ctx, abort := context.WithCancel(withExecutor(context.Background(), &executor{
+		client:    client,
+		namespace: "chaos",
+		report:    statuses.report,
+	}))
+	defer abort()
+	chaosJob := &ChaosJob{
+		Name: "stress",
+		Type: string(types.LitmusPodIoStress),
+		Config: map[string]string{
+			"APP_NAMESPACE":        "app",
+			"APP_LABEL":            "app=nginx",
+			"PODS_AFFECTED_PERC":   "100",
+			"TOTAL_CHAOS_DURATION": "60",
+		},
+	}
+
+	stepDone := make(chan struct{})
+	go func() {
+		defer close(stepDone)
+		runLitmusStress(ctx, chaosJob, 1)
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for statuses.last().Status != RunningStatus {
+		if time.Now().After(deadline) {
+			t.Fatal("step is not running")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// the pods becoming ready again make the watch write the config while the step is aborted
+	updatesDone := make(chan struct{})
+	go func() {
+		defer close(updatesDone)
+		for i := 0; ; i++ {
+			select {
+			case <-stepDone:
+				return
+			default:
+			}
+			pod := readyPod(fmt.Sprintf("nginx-%d", i%2+1))
+			pod.ResourceVersion = fmt.Sprintf("%d", i)
+			_, _ = client.CoreV1().Pods("app").Update(context.Background(), pod, metaV1.UpdateOptions{})
+			// the fake watch panics when its buffer is full
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	time.Sleep(50 * time.Millisecond)
+	abort()
+	select {
+	case <-stepDone:
+	case <-time.After(10 * time.Second):
+		t.Fatal("step did not end after the abort")
+	}
+	<-updatesDone
+
+	if last := statuses.last(); last.Status != AbortedStatus {
+		t.Errorf("last status = %s (%s), want aborted", last.Status, last.FailedReason)
+	}
+	jobs, err := client.BatchV1().Jobs("chaos").List(context.Background(), metaV1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs.Items) != 0 {
+		t.Errorf("%d chaos jobs left after the abort", len(jobs.Items))
+	}
+}
@@ -22,83 +22,212 @@
 package chaos
 
 import (
+	"archive/tar"
+	"compress/gzip"
 	"context"
 	"fmt"
-	"github.com/sirupsen/logrus"
-	"godzilla/env"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"godzilla/storage"
 	"io"
 	coreV1 "k8s.io/api/core/v1"
 	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/watch"
+	"net/http"
 	"os"
-	"reflect"
 	"strings"
+	"sync"
 	"time"
 )
 
-func (chaosJob *ChaosJob) fetchChaosLogs(actualName string) {
-	pod := client.CoreV1().Pods(env.JobNamespace)
-	w, err := client.CoreV1().Pods(env.JobNamespace).Watch(context.TODO(), metaV1.ListOptions{
-		LabelSelector: "chaos.job=true",
+// logDrainTimeout is how long the end of a step waits for the log streams of its pods.
+const logDrainTimeout = 30 * time.Second
+
+func logPrefix(jobStatusId uint) string {
+	return fmt.Sprintf("logs/%v/", jobStatusId)
+}
+
+// logKey is logs/<run id>/<step>/<pod>/<container>.log
+func logKey(jobStatusId uint, step, pod, container string) string {
+	return fmt.Sprintf("%s%s/%s/%s.log", logPrefix(jobStatusId), step, pod, container)
+}
+
+// captureLogs streams the logs of every container of the chaos job pods of the step into the log store,
+// the litmus runners as well as the stress helpers. The returned func stops watching for new pods and
+// waits for the streams, they end when cleanJob deletes the pods.
+func (h *Handler) captureLogs(ctx context.Context, chaosJob *ChaosJob, jobStatusId uint) func() {
+	logger := logFrom(ctx)
+	ctx = context.WithoutCancel(ctx)
+	watchCtx, stopWatch := context.WithCancel(ctx)
+	kubeCtx, done := kubeCall(watchCtx, "watch_pods")
+	kube := executorFrom(ctx)
+	w, err := kube.client.CoreV1().Pods(kube.namespace).Watch(kubeCtx, metaV1.ListOptions{
+		LabelSelector: fmt.Sprintf("chaos.job.id=%v,chaos.job.name=%s", jobStatusId, chaosJob.Name),
 	})
+	done(err)
 	if err != nil {
-		logrus.Error(err)
-		return
+		logger.Warnf("watch chaos job pods for logs failed, reason: %s", err.Error())
+		return stopWatch
+	}
+	var streams sync.WaitGroup
+	watchDone := make(chan struct{})
+	go func() {
+		defer close(watchDone)
+		streamed := make(map[string]bool)
+		for event := range w.ResultChan() {
+			pod, ok := event.Object.(*coreV1.Pod)
+			if !ok || event.Type == watch.Deleted {
+				continue
+			}
+			statuses := append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...)
+			for _, cs := range statuses {
+				if cs.State.Running == nil && cs.State.Terminated == nil {
+					continue
+				}
+				key := logKey(jobStatusId, chaosJob.Name, pod.Name, cs.Name)
+				if streamed[key] {
+					continue
+				}
+				streamed[key] = true
+				streams.Add(1)
+				go func(podName, container, key string) {
+					defer streams.Done()
+					err := h.streamLogs(ctx, podName, container, key)
+					if err != nil {
+						logger.WithField(JobNameField, pod.Labels["job-name"]).
+							Warnf("capture logs of pod %s container %s failed, reason: %s", podName, container, err.Error())
+					}
+				}(pod.Name, cs.Name, key)
+			}
+		}
+	}()
+	return func() {
+		w.Stop()
+		stopWatch()
+		<-watchDone
+		drained := make(chan struct{})
+		go func() {
+			streams.Wait()
+			close(drained)
+		}()
+		select {
+		case <-drained:
+		case <-time.After(logDrainTimeout):
+			logger.Warn("log streams still open after the step ended, they are kept in the background")
+		}
 	}
+}
 
-	for event := range w.ResultChan() {
-		if event.Object != nil && event.Type != watch.Error && event.Type != watch.Deleted {
-			if reflect.ValueOf(event.Object).Type().Elem().Name() == "Pod" {
-				podObject := event.Object.(*coreV1.Pod)
-				if strings.Contains(podObject.Name, actualName) {
-					for _, cs := range podObject.Status.ContainerStatuses {
-						if cs.State.Waiting != nil {
-							continue
-						}
-						logs := pod.GetLogs(podObject.ObjectMeta.Name,
-							&coreV1.PodLogOptions{Follow: true, Container: cs.Name, Timestamps: true})
+func (h *Handler) streamLogs(ctx context.Context, podName, container, key string) error {
+	kubeCtx, done := kubeCall(ctx, "stream_logs", attribute.String("pod", podName))
+	kube := executorFrom(ctx)
+	reader, err := kube.client.CoreV1().Pods(kube.namespace).GetLogs(podName, &coreV1.PodLogOptions{
+		Container:  container,
+		Follow:     true,
+		Timestamps: true,
+	}).Stream(kubeCtx)
+	done(err)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	return h.logs.Put(ctx, key, reader)
+}
 
-						reader, err := logs.Stream(context.TODO())
-						if err != nil {
-							logrus.Warning(err)
-							w.Stop()
-							return
-						}
+func (h *Handler) ListLogs(c *gin.Context) {
+	id, err := idParam(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse(RequestError, err))
+		return
+	}
+	keys, err := h.logs.List(c.Request.Context(), logPrefix(uint(id)))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse(LogStoreError, err))
+		return
+	}
+	files := make([]string, 0, len(keys))
+	for _, key := range keys {
+		files = append(files, strings.TrimPrefix(key, logPrefix(uint(id))))
+	}
+	c.JSON(http.StatusOK, NormalResponse(Ok, files))
+}
 
-						os.Mkdir("logs", 0755)
-						objectName := fmt.Sprintf("logs/%s.log", actualName)
-						f, err := os.OpenFile(objectName, os.O_CREATE|os.O_RDWR, 0644)
-						if err != nil {
-							logrus.Fatal(err)
-						}
-						offset := 0
-						t := time.NewTicker(3 * time.Second)
+// DownloadLogs sends the logs of the run, or of one of its steps, as a tar.gz archive.
+func (h *Handler) DownloadLogs(c *gin.Context) {
+	id, err := idParam(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse(RequestError, err))
+		return
+	}
+	prefix := logPrefix(uint(id))
+	log := logFrom(c.Request.Context()).WithField(RunIdField, id)
+	if step := c.Query("step"); step != "" {
+		prefix += step + "/"
+		log = log.WithField(StepField, step)
+	}
+	ctx := c.Request.Context()
+	keys, err := h.logs.List(ctx, prefix)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse(LogStoreError, err))
+		return
+	}
+	if len(keys) == 0 {
+		c.AbortWithStatusJSON(http.StatusNotFound, ErrorResponse(LogNotFound, nil))
+		return
+	}
+	c.Header("Content-Type", "application/gzip")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=run-%v-logs.tar.gz", id))
+	c.Status(http.StatusOK)
+	err = writeArchive(ctx, c.Writer, h.logs, keys, logPrefix(uint(id)), "")
+	if err != nil {
+		// the headers are sent already, the archive is cut short
+		log.Errorf("write logs archive failed, reason: %s", err.Error())
+	}
+}
 
-						for {
-							buf := make([]byte, 1024*1024)
-							numBytes, err := reader.Read(buf)
-							if err == io.EOF {
-								t.Stop()
-								w.Stop()
-								break
-							}
-							if err != nil {
-								t.Stop()
-								w.Stop()
-								logrus.Error(err)
-								return
-							}
-							_, err = f.WriteAt(buf[:numBytes], int64(offset))
-							if err != nil {
-								logrus.Error(err)
-								w.Stop()
-								return
-							}
-							offset += numBytes
-						}
-					}
-				}
-			}
+// writeArchive writes the objects as a tar.gz, their names are the keys with prefix replaced by root.
+func writeArchive(ctx context.Context, w io.Writer, store storage.Store, keys []string, prefix, root string) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, key := range keys {
+		err := writeTarEntry(ctx, tw, store, key, root+strings.TrimPrefix(key, prefix))
+		if err != nil {
+			return err
 		}
 	}
+	err := tw.Close()
+	if err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// writeTarEntry copies the object into the archive, it is spooled into a temporary file first since
+// the header needs its size.
+func writeTarEntry(ctx context.Context, tw *tar.Writer, store storage.Store, key, name string) error {
+	r, err := store.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	f, err := os.CreateTemp("", "godzilla-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	size, err := io.Copy(f, r)
+	if err != nil {
+		return err
+	}
+	_, err = f.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	err = tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: size, ModTime: time.Now()})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
 }
@@ -0,0 +1,71 @@
+/*
+ *
+ *  * Licensed to the Apache Software Foundation (ASF) under one
+ *  * or more contributor license agreements.  See the NOTICE file
+ *  * distributed with this work for additional information
+ *  * regarding copyright ownership.  The ASF licenses this file
+ *  * to you under the Apache License, Version 2.0 (the
+ *  * "License"); you may not use this file except in compliance
+ *  * with the License.  You may obtain a copy of the License at
+ *  *
+ *  *     http://www.apache.org/licenses/LICENSE-2.0
+ *  *
+ *  * Unless required by applicable law or agreed to in writing, software
+ *  * distributed under the License is distributed on an "AS IS" BASIS,
+ *  * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  * See the License for the specific language governing permissions and
+ *  * limitations under the License.
+ *
+ *
+ */
+
+package chaos
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+)
+
+// the structured fields of the logs, so that the logs of a run can be found without parsing the messages
+const (
+	RequestIdField = "request_id"
+	RunIdField     = "run_id"
+	ScenarioField  = "scenario"
+	StepField      = "step"
+	JobNameField   = "job_name"
+	TargetPodField = "target_pod"
+)
+
+type logFieldsKey struct{}
+
+// withLogFields adds the fields to the ones already kept in the context.
+func withLogFields(ctx context.Context, fields logrus.Fields) context.Context {
+	merged := logrus.Fields{}
+	for k, v := range logFieldsOf(ctx) {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, logFieldsKey{}, merged)
+}
+
+func logFieldsOf(ctx context.Context) logrus.Fields {
+	fields, _ := ctx.Value(logFieldsKey{}).(logrus.Fields)
+	return fields
+}
+
+// logFrom returns a logger carrying the fields of the context.
+func logFrom(ctx context.Context) *logrus.Entry {
+	return logrus.WithFields(logFieldsOf(ctx))
+}
+
+// runLog returns a logger for a run when no context of the run is at hand, e.g. in the status worker.
+func runLog(runId uint) *logrus.Entry {
+	return logrus.WithField(RunIdField, runId)
+}
+
+// WithRequestId keeps the id of the API request, the runs started by the request log it as well.
+func WithRequestId(ctx context.Context, requestId string) context.Context {
+	return withLogFields(ctx, logrus.Fields{RequestIdField: requestId})
+}
@@ -0,0 +1,60 @@
+/*
+ *
+ *  * Licensed to the Apache Software Foundation (ASF) under one
+ *  * or more contributor license agreements.  See the NOTICE file
+ *  * distributed with this work for additional information
+ *  * regarding copyright ownership.  The ASF licenses this file
+ *  * to you under the Apache License, Version 2.0 (the
+ *  * "License"); you may not use this file except in compliance
+ *  * with the License.  You may obtain a copy of the License at
+ *  *
+ *  *     http://www.apache.org/licenses/LICENSE-2.0
+ *  *
+ *  * Unless required by applicable law or agreed to in writing, software
+ *  * distributed under the License is distributed on an "AS IS" BASIS,
+ *  * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  * See the License for the specific language governing permissions and
+ *  * limitations under the License.
+ *
+ *
+ */
+
+package chaos
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	runsStarted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "godzilla_runs_started_total",
+		Help: "Number of runs started, by scenario.",
+	}, []string{"scenario"})
+	runsFinished = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "godzilla_runs_finished_total",
+		Help: "Number of runs finished, by scenario and verdict.",
+	}, []string{"scenario", "verdict"})
+	stepDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "godzilla_step_duration_seconds",
+		Help:    "Time from a step running to its final status, by experiment type and status.",
+		Buckets: []float64{5, 15, 30, 60, 120, 300, 600, 1800, 3600},
+	}, []string{"type", "status"})
+	kubeApiErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "godzilla_kube_api_errors_total",
+		Help: "Number of failed Kubernetes API calls, by operation.",
+	}, []string{"operation"})
+	chaosJobsActive = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "godzilla_chaos_jobs_active",
+		Help: "Number of chaos Jobs created and not cleaned up yet, by experiment type and target namespace.",
+	}, []string{"type", "app_namespace"})
+	_ = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "godzilla_status_queue_depth",
+		Help: "Number of status updates waiting for the status worker.",
+	}, func() float64 {
+		return float64(len(statusChan))
+	})
+)
+
+// adHocScenario is the scenario label of the runs created from a request body.
+const adHocScenario = "ad-hoc"
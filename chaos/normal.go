@@ -21,9 +21,11 @@
 
 package chaos
 
+import "godzilla/types"
+
 func NormalResponse(code int, body any) Response {
 	return Response{
-		Status:  normalStatus,
+		Status:  types.NormalStatus,
 		Message: normalMsgMap[code],
 		Body:    body,
 	}
@@ -33,10 +35,18 @@ const (
 	Health = iota
 	Ok
 	TaskCreated
+	RunSucceeded
+	RunFailed
+	RunAborted
+	RunPending
 )
 
 var normalMsgMap = map[int]string{
-	Health:      "ok",
-	Ok:          "ok",
-	TaskCreated: "task created",
+	Health:       "ok",
+	Ok:           "ok",
+	TaskCreated:  "task created",
+	RunSucceeded: "run succeeded",
+	RunFailed:    "run failed",
+	RunAborted:   "run aborted",
+	RunPending:   "run is not finished yet",
 }
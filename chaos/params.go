@@ -19,27 +19,23 @@
  *
  */
 
-package db
+package chaos
 
 import (
-	"fmt"
-	"github.com/sirupsen/logrus"
-	"godzilla/env"
-	"gorm.io/driver/mysql"
-	"gorm.io/gorm"
+	"github.com/gin-gonic/gin"
+	"strconv"
 )
 
-var Db *gorm.DB
-
-func Open() {
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=%s", env.MysqlUser, env.MysqlPassword, env.MysqlHost,
-		env.MysqlPort, env.MysqlDatabase, "utf8mb4&parseTime=True")
-	conn, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
-		SkipDefaultTransaction: true,
-		PrepareStmt:            true,
-	})
-	if err != nil {
-		logrus.Fatal(err)
+// param returns the path parameter of the /api/v1 routes, or the query key of the deprecated
+// routes which have no path parameters.
+func param(c *gin.Context, name, key string) string {
+	if value := c.Param(name); value != "" {
+		return value
 	}
-	Db = conn
+	return c.Query(key)
+}
+
+// idParam parses the id of the run, webhook or notification the request is about.
+func idParam(c *gin.Context) (uint64, error) {
+	return strconv.ParseUint(param(c, "id", "id"), 10, 64)
 }
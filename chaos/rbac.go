@@ -27,10 +27,11 @@ import (
 	rbacV1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
 )
 
-func addServiceAccount(namespace string) error {
-	_, err := client.CoreV1().ServiceAccounts(namespace).Create(context.TODO(), &coreV1.ServiceAccount{
+func addServiceAccount(ctx context.Context, client kubernetes.Interface, namespace string) error {
+	_, err := client.CoreV1().ServiceAccounts(namespace).Create(ctx, &coreV1.ServiceAccount{
 		ObjectMeta: metaV1.ObjectMeta{
 			Name: "chaos-admin",
 			Labels: map[string]string{
@@ -38,15 +39,14 @@ func addServiceAccount(namespace string) error {
 			},
 		},
 	}, metaV1.CreateOptions{})
-	if err.(*errors.StatusError).ErrStatus.Reason != metaV1.StatusReasonAlreadyExists {
-		return err
-	} else {
+	if errors.IsAlreadyExists(err) {
 		return nil
 	}
+	return err
 }
 
-func addRoleBinding(namespace string) error {
-	_, err := client.RbacV1().ClusterRoleBindings().Create(context.TODO(), &rbacV1.ClusterRoleBinding{
+func addRoleBinding(ctx context.Context, client kubernetes.Interface, namespace string) error {
+	_, err := client.RbacV1().ClusterRoleBindings().Create(ctx, &rbacV1.ClusterRoleBinding{
 		ObjectMeta: metaV1.ObjectMeta{
 			Name: "chaos-admin",
 			Labels: map[string]string{
@@ -66,15 +66,14 @@ func addRoleBinding(namespace string) error {
 			Name:     "chaos-admin",
 		},
 	}, metaV1.CreateOptions{})
-	if err.(*errors.StatusError).ErrStatus.Reason != metaV1.StatusReasonAlreadyExists {
-		return err
-	} else {
+	if errors.IsAlreadyExists(err) {
 		return nil
 	}
+	return err
 }
 
-func addClusterRole() error {
-	_, err := client.RbacV1().ClusterRoles().Create(context.TODO(), &rbacV1.ClusterRole{
+func addClusterRole(ctx context.Context, client kubernetes.Interface) error {
+	_, err := client.RbacV1().ClusterRoles().Create(ctx, &rbacV1.ClusterRole{
 		ObjectMeta: metaV1.ObjectMeta{
 			Name: "chaos-admin",
 			Labels: map[string]string{
@@ -93,9 +92,8 @@ func addClusterRole() error {
 			},
 		},
 	}, metaV1.CreateOptions{})
-	if err.(*errors.StatusError).ErrStatus.Reason != metaV1.StatusReasonAlreadyExists {
-		return err
-	} else {
+	if errors.IsAlreadyExists(err) {
 		return nil
 	}
+	return err
 }
@@ -0,0 +1,386 @@
+/*
+ *
+ *  * Licensed to the Apache Software Foundation (ASF) under one
+ *  * or more contributor license agreements.  See the NOTICE file
+ *  * distributed with this work for additional information
+ *  * regarding copyright ownership.  The ASF licenses this file
+ *  * to you under the Apache License, Version 2.0 (the
+ *  * "License"); you may not use this file except in compliance
+ *  * with the License.  You may obtain a copy of the License at
+ *  *
+ *  *     http://www.apache.org/licenses/LICENSE-2.0
+ *  *
+ *  * Unless required by applicable law or agreed to in writing, software
+ *  * distributed under the License is distributed on an "AS IS" BASIS,
+ *  * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  * See the License for the specific language governing permissions and
+ *  * limitations under the License.
+ *
+ *
+ */
+
+package chaos
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"godzilla/db"
+	"godzilla/types"
+	"gopkg.in/yaml.v2"
+	htmlTemplate "html/template"
+	"net/http"
+	"sort"
+	"strings"
+	textTemplate "text/template"
+	"time"
+)
+
+const (
+	HtmlFormat     = types.HtmlFormat
+	MarkdownFormat = types.MarkdownFormat
+	JunitFormat    = types.JunitFormat
+)
+
+var reportFormats = map[string]bool{HtmlFormat: true, MarkdownFormat: true, JunitFormat: true}
+
+// Report is a finished run as rendered by the report endpoint.
+type Report struct {
+	RunId       uint
+	Scenario    string
+	TriggeredBy string
+	TraceId     string
+	Verdict     string
+	StartedAt   time.Time
+	FinishedAt  time.Time
+	Steps       []ReportStep
+	Events      []db.JobEvent
+}
+
+type ReportStep struct {
+	Stage        int
+	Name         string
+	Type         string
+	Status       JobStatus
+	FailedReason string
+	Config       map[string]string
+	TargetPods   []string
+	JobNames     []string
+	StartedAt    time.Time
+	FinishedAt   time.Time
+}
+
+func (r Report) Duration() time.Duration {
+	return r.FinishedAt.Sub(r.StartedAt).Round(time.Second)
+}
+
+// Duration is zero for the steps which never started.
+func (s ReportStep) Duration() time.Duration {
+	if s.StartedAt.IsZero() || s.FinishedAt.IsZero() {
+		return 0
+	}
+	return s.FinishedAt.Sub(s.StartedAt).Round(time.Second)
+}
+
+// Parameters returns the configuration of the step sorted by name.
+func (s ReportStep) Parameters() []string {
+	var params []string
+	for k, v := range s.Config {
+		params = append(params, fmt.Sprintf("%s=%s", k, v))
+	}
+	sort.Strings(params)
+	return params
+}
+
+// newReport builds the report from the status of the run and its events, a step starts with its
+// transition to running and finishes with its last transition.
+func (h *Handler) newReport(run db.JobStatus) (Report, error) {
+	var chaosJobs [][]ChaosJob
+	err := yaml.Unmarshal([]byte(run.Status), &chaosJobs)
+	if err != nil {
+		return Report{}, err
+	}
+	events, err := h.events.ListByRunId(run.Id)
+	if err != nil {
+		return Report{}, err
+	}
+	report := Report{
+		RunId:       run.Id,
+		Scenario:    adHocScenario,
+		TriggeredBy: run.TriggeredBy,
+		TraceId:     run.TraceId,
+		Verdict:     runVerdict(chaosJobs),
+		StartedAt:   run.CreatedAt,
+		FinishedAt:  run.UpdatedAt,
+		Events:      events,
+	}
+	if run.ScenarioId != 0 {
+		scenario, err := h.scenarios.GetById(run.ScenarioId)
+		if err != nil {
+			return Report{}, err
+		}
+		report.Scenario = scenario.Name
+	}
+	for i, parallelJobs := range chaosJobs {
+		for _, j := range parallelJobs {
+			step := ReportStep{
+				Stage:        i + 1,
+				Name:         j.Name,
+				Type:         j.Type,
+				Status:       j.Status,
+				FailedReason: j.FailedReason,
+				Config:       j.Config,
+				TargetPods:   j.TargetPods,
+				JobNames:     j.JobNames,
+			}
+			for _, event := range events {
+				if event.Step != j.Name {
+					continue
+				}
+				if event.ToStatus == string(RunningStatus) {
+					step.StartedAt = event.CreatedAt
+				} else {
+					step.FinishedAt = event.CreatedAt
+				}
+			}
+			report.Steps = append(report.Steps, step)
+		}
+	}
+	return report, nil
+}
+
+var reportFuncs = textTemplate.FuncMap{
+	"time": func(t time.Time) string {
+		if t.IsZero() {
+			return "-"
+		}
+		return t.UTC().Format(time.RFC3339)
+	},
+	"join": strings.Join,
+	"cell": func(s string) string {
+		return strings.ReplaceAll(strings.ReplaceAll(s, "|", "\\|"), "\n", " ")
+	},
+}
+
+const markdownReport = `## {{if eq .Verdict "success"}}:white_check_mark:{{else if eq .Verdict "aborted"}}:octagonal_sign:{{else}}:x:{{end}} godzilla run #{{.RunId}}: {{.Scenario}} {{.Verdict}}
+
+| | |
+|---|---|
+| scenario | {{.Scenario}} |
+| triggered by | {{if .TriggeredBy}}{{.TriggeredBy}}{{else}}-{{end}} |
+| started | {{time .StartedAt}} |
+| finished | {{time .FinishedAt}} |
+| duration | {{.Duration}} |
+{{- if .TraceId}}
+| trace id | {{.TraceId}} |
+{{- end}}
+
+### Steps
+
+| stage | step | type | status | duration | targets | failure |
+|---|---|---|---|---|---|---|
+{{- range .Steps}}
+| {{.Stage}} | {{.Name}} | {{.Type}} | {{.Status}} | {{.Duration}} | {{join .TargetPods ", "}} | {{cell .FailedReason}} |
+{{- end}}
+
+### Parameters
+{{range .Steps}}
+<details><summary>{{.Name}}</summary>
+
+` + "```" + `
+{{range .Parameters}}{{.}}
+{{end}}` + "```" + `
+</details>
+{{end}}
+### Timeline
+
+| time | step | from | to | reason |
+|---|---|---|---|---|
+{{- range .Events}}
+| {{time .CreatedAt}} | {{.Step}} | {{.FromStatus}} | {{.ToStatus}} | {{cell .Reason}} |
+{{- end}}
+`
+
+const htmlReport = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>godzilla run #{{.RunId}}: {{.Scenario}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; vertical-align: top; }
+th { background: #f4f4f4; }
+pre { margin: 0; font-size: 0.9em; }
+.success { color: #1a7f37; } .failed, .unknown { color: #cf222e; } .aborted, .pending, .running { color: #9a6700; }
+</style>
+</head>
+<body>
+<h1>godzilla run #{{.RunId}}: {{.Scenario}} <span class="{{.Verdict}}">{{.Verdict}}</span></h1>
+<table>
+<tr><th>scenario</th><td>{{.Scenario}}</td></tr>
+<tr><th>triggered by</th><td>{{if .TriggeredBy}}{{.TriggeredBy}}{{else}}-{{end}}</td></tr>
+<tr><th>started</th><td>{{time .StartedAt}}</td></tr>
+<tr><th>finished</th><td>{{time .FinishedAt}}</td></tr>
+<tr><th>duration</th><td>{{.Duration}}</td></tr>
+{{- if .TraceId}}
+<tr><th>trace id</th><td>{{.TraceId}}</td></tr>
+{{- end}}
+</table>
+<h2>Steps</h2>
+<table>
+<tr><th>stage</th><th>step</th><th>type</th><th>status</th><th>started</th><th>duration</th><th>targets</th><th>jobs</th><th>parameters</th><th>failure</th></tr>
+{{- range .Steps}}
+<tr><td>{{.Stage}}</td><td>{{.Name}}</td><td>{{.Type}}</td><td class="{{.Status}}">{{.Status}}</td><td>{{time .StartedAt}}</td><td>{{.Duration}}</td>
+<td>{{join .TargetPods ", "}}</td><td>{{join .JobNames ", "}}</td><td><pre>{{join .Parameters "\n"}}</pre></td><td>{{.FailedReason}}</td></tr>
+{{- end}}
+</table>
+<h2>Timeline</h2>
+<table>
+<tr><th>time</th><th>step</th><th>from</th><th>to</th><th>reason</th></tr>
+{{- range .Events}}
+<tr><td>{{time .CreatedAt}}</td><td>{{.Step}}</td><td>{{.FromStatus}}</td><td class="{{.ToStatus}}">{{.ToStatus}}</td><td>{{.Reason}}</td></tr>
+{{- end}}
+</table>
+</body>
+</html>
+`
+
+var (
+	markdownReportTemplate = textTemplate.Must(textTemplate.New("markdown").Funcs(reportFuncs).Parse(markdownReport))
+	htmlReportTemplate     = htmlTemplate.Must(htmlTemplate.New("html").Funcs(htmlTemplate.FuncMap(reportFuncs)).Parse(htmlReport))
+)
+
+type junitTestSuites struct {
+	XMLName    xml.Name         `xml:"testsuites"`
+	Name       string           `xml:"name,attr"`
+	Tests      int              `xml:"tests,attr"`
+	Failures   int              `xml:"failures,attr"`
+	Skipped    int              `xml:"skipped,attr"`
+	Time       float64          `xml:"time,attr"`
+	TestSuites []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      float64         `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+// junitReport makes one test case per step, the failed and unknown steps fail and the aborted
+// or never started ones are skipped.
+func junitReport(report Report) ([]byte, error) {
+	suite := junitTestSuite{
+		Name:      fmt.Sprintf("godzilla.%s", report.Scenario),
+		Time:      report.Duration().Seconds(),
+		Timestamp: report.StartedAt.UTC().Format("2006-01-02T15:04:05"),
+	}
+	for _, step := range report.Steps {
+		testCase := junitTestCase{
+			ClassName: fmt.Sprintf("godzilla.%s.stage-%d", report.Scenario, step.Stage),
+			Name:      step.Name,
+			Time:      step.Duration().Seconds(),
+			SystemOut: fmt.Sprintf("type: %s\ntargets: %s\njobs: %s\nparameters:\n%s\n", step.Type,
+				strings.Join(step.TargetPods, ", "), strings.Join(step.JobNames, ", "), strings.Join(step.Parameters(), "\n")),
+		}
+		switch step.Status {
+		case SuccessStatus:
+		case AbortedStatus, PendingStatus:
+			testCase.Skipped = &junitMessage{Message: string(step.Status)}
+			suite.Skipped++
+		default:
+			testCase.Failure = &junitMessage{Message: step.FailedReason, Type: string(step.Status), Text: step.FailedReason}
+			suite.Failures++
+		}
+		suite.Tests++
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+	data, err := xml.MarshalIndent(junitTestSuites{
+		Name:       fmt.Sprintf("godzilla run %v", report.RunId),
+		Tests:      suite.Tests,
+		Failures:   suite.Failures,
+		Skipped:    suite.Skipped,
+		Time:       suite.Time,
+		TestSuites: []junitTestSuite{suite},
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+// renderReport returns the report in the format and its content type.
+func renderReport(report Report, format string) ([]byte, string, error) {
+	var buf bytes.Buffer
+	switch format {
+	case HtmlFormat:
+		err := htmlReportTemplate.Execute(&buf, report)
+		return buf.Bytes(), "text/html; charset=utf-8", err
+	case MarkdownFormat:
+		err := markdownReportTemplate.Execute(&buf, report)
+		return buf.Bytes(), "text/markdown; charset=utf-8", err
+	case JunitFormat:
+		data, err := junitReport(report)
+		return data, "application/xml; charset=utf-8", err
+	default:
+		return nil, "", fmt.Errorf("unsupported format %s", format)
+	}
+}
+
+// GetReport renders a finished run as html, markdown or junit xml, chosen by the format query, html by default.
+func (h *Handler) GetReport(c *gin.Context) {
+	id, err := idParam(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse(RequestError, err))
+		return
+	}
+	format := c.DefaultQuery("format", HtmlFormat)
+	if !reportFormats[format] {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse(RequestError, fmt.Errorf("unsupported format %s", format)))
+		return
+	}
+	run, err := h.runs.GetById(uint(id))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse(MySqlError, err))
+		return
+	}
+	if run.Id == 0 {
+		c.AbortWithStatusJSON(http.StatusNotFound, ErrorResponse(RunNotFound, nil))
+		return
+	}
+	if !runFinished(run) {
+		c.AbortWithStatusJSON(http.StatusConflict, ErrorResponse(RunNotFinished, nil))
+		return
+	}
+	report, err := h.newReport(run)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse(ReportError, err))
+		return
+	}
+	data, contentType, err := renderReport(report, format)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse(ReportError, err))
+		return
+	}
+	c.Data(http.StatusOK, contentType, data)
+}
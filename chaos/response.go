@@ -21,15 +21,6 @@
 
 package chaos
 
-type status string
+import "godzilla/types"
 
-var (
-	errorStatus  status = "error"
-	normalStatus status = "normal"
-)
-
-type Response struct {
-	Status  status `json:"status"`
-	Message string `json:"message"`
-	Body    any    `json:"body,omitempty"`
-}
+type Response = types.Response
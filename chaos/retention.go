@@ -0,0 +1,248 @@
+/*
+ *
+ *  * Licensed to the Apache Software Foundation (ASF) under one
+ *  * or more contributor license agreements.  See the NOTICE file
+ *  * distributed with this work for additional information
+ *  * regarding copyright ownership.  The ASF licenses this file
+ *  * to you under the Apache License, Version 2.0 (the
+ *  * "License"); you may not use this file except in compliance
+ *  * with the License.  You may obtain a copy of the License at
+ *  *
+ *  *     http://www.apache.org/licenses/LICENSE-2.0
+ *  *
+ *  * Unless required by applicable law or agreed to in writing, software
+ *  * distributed under the License is distributed on an "AS IS" BASIS,
+ *  * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  * See the License for the specific language governing permissions and
+ *  * limitations under the License.
+ *
+ *
+ */
+
+package chaos
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"godzilla/db"
+	"godzilla/env"
+	"gopkg.in/yaml.v2"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+type retentionPolicy struct {
+	maxAge   time.Duration
+	maxRuns  int
+	interval time.Duration
+}
+
+func parseRetentionPolicy() (policy retentionPolicy, err error) {
+	if env.RunRetentionMaxAge != "" {
+		policy.maxAge, err = time.ParseDuration(env.RunRetentionMaxAge)
+		if err != nil {
+			return policy, fmt.Errorf("parse RUN_RETENTION_MAX_AGE error, reason: %s", err.Error())
+		}
+	}
+	policy.maxRuns, err = strconv.Atoi(env.RunRetentionMaxRuns)
+	if err != nil {
+		return policy, fmt.Errorf("parse RUN_RETENTION_MAX_RUNS error, reason: %s", err.Error())
+	}
+	policy.interval, err = time.ParseDuration(env.RunRetentionInterval)
+	if err != nil {
+		return policy, fmt.Errorf("parse RUN_RETENTION_INTERVAL error, reason: %s", err.Error())
+	}
+	if policy.interval <= 0 {
+		return policy, fmt.Errorf("RUN_RETENTION_INTERVAL must be positive")
+	}
+	return policy, nil
+}
+
+// RetentionWorker purges the finished runs older than RUN_RETENTION_MAX_AGE and the ones beyond
+// the newest RUN_RETENTION_MAX_RUNS of each scenario, the worker exits when both are disabled.
+func (h *Handler) RetentionWorker() {
+	policy, err := parseRetentionPolicy()
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	if policy.maxAge == 0 && policy.maxRuns == 0 {
+		logrus.Info("run retention is disabled")
+		return
+	}
+	t := time.NewTicker(policy.interval)
+	defer t.Stop()
+	for {
+		h.enforceRetention(policy)
+		<-t.C
+	}
+}
+
+func (h *Handler) enforceRetention(policy retentionPolicy) {
+	var runs []db.JobStatus
+	if policy.maxAge > 0 {
+		expired, err := h.runs.ListBefore(time.Now().Add(-policy.maxAge))
+		if err != nil {
+			logrus.Errorf("list expired runs failed, reason: %s", err.Error())
+		}
+		runs = append(runs, expired...)
+	}
+	if policy.maxRuns > 0 {
+		scenarioIds, err := h.runs.ScenarioIds()
+		if err != nil {
+			logrus.Errorf("list scenarios of runs failed, reason: %s", err.Error())
+		}
+		for _, scenarioId := range scenarioIds {
+			exceeded, err := h.runs.ListByScenarioId(scenarioId, policy.maxRuns)
+			if err != nil {
+				logrus.Errorf("list runs of scenario %v failed, reason: %s", scenarioId, err.Error())
+				continue
+			}
+			runs = append(runs, exceeded...)
+		}
+	}
+
+	purged := make(map[uint]bool)
+	for _, run := range runs {
+		if purged[run.Id] || !runFinished(run) {
+			continue
+		}
+		err := h.purgeRun(run)
+		if err != nil {
+			runLog(run.Id).Errorf("purge run failed, reason: %s", err.Error())
+			continue
+		}
+		purged[run.Id] = true
+	}
+	if len(purged) > 0 {
+		logrus.Infof("retention purged %d runs", len(purged))
+	}
+}
+
+// runFinished reports whether none of the steps is pending or running anymore,
+// a status which can not be read counts as finished so that it can be purged.
+func runFinished(run db.JobStatus) bool {
+	var chaosJobs [][]ChaosJob
+	err := yaml.Unmarshal([]byte(run.Status), &chaosJobs)
+	if err != nil {
+		return true
+	}
+	return stepsFinished(chaosJobs)
+}
+
+func stepsFinished(chaosJobs [][]ChaosJob) bool {
+	for _, parallelJobs := range chaosJobs {
+		for _, j := range parallelJobs {
+			if j.Status == PendingStatus || j.Status == RunningStatus {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (h *Handler) purgeRun(run db.JobStatus) error {
+	events, err := h.events.ListByRunId(run.Id)
+	if err != nil {
+		return err
+	}
+	if env.RunArchiveDir != "" {
+		err = archiveRun(env.RunArchiveDir, run, events)
+		if err != nil {
+			return err
+		}
+	}
+	err = h.logs.DeletePrefix(context.Background(), logPrefix(run.Id))
+	if err != nil {
+		return err
+	}
+	err = h.artifacts.DeletePrefix(context.Background(), evidencePrefix(run.Id))
+	if err != nil {
+		return err
+	}
+	err = h.events.DeleteByRunId(run.Id)
+	if err != nil {
+		return err
+	}
+	return h.runs.DeleteById(run.Id)
+}
+
+type runArchive struct {
+	Id         uint          `json:"id"`
+	ScenarioId uint          `json:"scenarioId"`
+	CreatedAt  time.Time     `json:"createdAt"`
+	UpdatedAt  time.Time     `json:"updatedAt"`
+	Status     string        `json:"status"`
+	Events     []db.JobEvent `json:"events"`
+}
+
+// archiveRun writes the run and its events into dir/run-<id>.json.gz.
+func archiveRun(dir string, run db.JobStatus, events []db.JobEvent) error {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+	name := filepath.Join(dir, fmt.Sprintf("run-%d.json.gz", run.Id))
+	f, err := os.CreateTemp(dir, ".run-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	err = json.NewEncoder(gz).Encode(runArchive{
+		Id:         run.Id,
+		ScenarioId: run.ScenarioId,
+		CreatedAt:  run.CreatedAt,
+		UpdatedAt:  run.UpdatedAt,
+		Status:     run.Status,
+		Events:     events,
+	})
+	if err != nil {
+		return err
+	}
+	err = gz.Close()
+	if err != nil {
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), name)
+}
+
+func (h *Handler) PurgeRun(c *gin.Context) {
+	id, err := idParam(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse(RequestError, err))
+		return
+	}
+	run, err := h.runs.GetById(uint(id))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse(MySqlError, err))
+		return
+	}
+	if run.Id == 0 {
+		c.AbortWithStatusJSON(http.StatusNotFound, ErrorResponse(RunNotFound, nil))
+		return
+	}
+	if !runFinished(run) {
+		c.AbortWithStatusJSON(http.StatusConflict, ErrorResponse(RunNotFinished, nil))
+		return
+	}
+	err = h.purgeRun(run)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse(RunPurgeError, err))
+		return
+	}
+	runLog(run.Id).Info("run purged")
+	c.JSON(http.StatusOK, NormalResponse(Ok, run.Id))
+}
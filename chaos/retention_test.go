@@ -0,0 +1,103 @@
+/*
+ *
+ *  * Licensed to the Apache Software Foundation (ASF) under one
+ *  * or more contributor license agreements.  See the NOTICE file
+ *  * distributed with this work for additional information
+ *  * regarding copyright ownership.  The ASF licenses this file
+ *  * to you under the Apache License, Version 2.0 (the
+ *  * "License"); you may not use this file except in compliance
+ *  * with the License.  You may obtain a copy of the License at
+ *  *
+ *  *     http://www.apache.org/licenses/LICENSE-2.0
+ *  *
+ *  * Unless required by applicable law or agreed to in writing, software
+ *  * distributed under the License is distributed on an "AS IS" BASIS,
+ *  * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  * See the License for the specific language governing permissions and
+ *  * limitations under the License.
+ *
+ *
+ */
+
+package chaos
+
+import (
+	"context"
+	"godzilla/db"
+	"godzilla/storage"
+	"gopkg.in/yaml.v2"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func runStatus(t *testing.T, status JobStatus) string {
+	t.Helper()
+	data, err := yaml.Marshal([][]ChaosJob{{{Name: "step", Status: status}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestEnforceRetention(t *testing.T) {
+	dir := t.TempDir()
+	store, err := db.OpenDriver(db.SqliteDriver, filepath.Join(dir, "godzilla.db"))
+	if err != nil {
+		t.Fatalf("open sqlite failed, reason: %s", err.Error())
+	}
+	logs, err := storage.NewLocalStore(filepath.Join(dir, "logs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	artifacts, err := storage.NewLocalStore(filepath.Join(dir, "artifacts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewHandler(store, logs, artifacts)
+
+	// from the oldest: a run still running, 2 finished runs which exceed the limit and the newest one
+	runs := []*db.JobStatus{
+		{ScenarioId: 1, Status: runStatus(t, RunningStatus)},
+		{ScenarioId: 1, Status: runStatus(t, SuccessStatus)},
+		{ScenarioId: 1, Status: runStatus(t, FailedStatus)},
+		{ScenarioId: 1, Status: runStatus(t, SuccessStatus)},
+		{ScenarioId: 2, Status: runStatus(t, SuccessStatus)},
+	}
+	ctx := context.Background()
+	for _, run := range runs {
+		err = store.Runs.Add(run)
+		if err != nil {
+			t.Fatalf("add run failed, reason: %s", err.Error())
+		}
+		err = store.Events.Add(&db.JobEvent{RunId: run.Id, Step: "step", ToStatus: string(PendingStatus)})
+		if err != nil {
+			t.Fatalf("add event failed, reason: %s", err.Error())
+		}
+		err = logs.Put(ctx, logKey(run.Id, "step", "pod", "container"), strings.NewReader("log"))
+		if err != nil {
+			t.Fatalf("put log failed, reason: %s", err.Error())
+		}
+	}
+
+	h.enforceRetention(retentionPolicy{maxRuns: 1})
+
+	for i, run := range runs {
+		purged := i == 1 || i == 2
+		stored, err := store.Runs.GetById(run.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if purged != (stored.Id == 0) {
+			t.Errorf("run %d purged = %v, want %v", i, stored.Id == 0, purged)
+		}
+		events, _ := store.Events.ListByRunId(run.Id)
+		if purged != (len(events) == 0) {
+			t.Errorf("run %d has %d events left, purged %v", i, len(events), purged)
+		}
+		keys, _ := logs.List(ctx, logPrefix(run.Id))
+		if purged != (len(keys) == 0) {
+			t.Errorf("run %d has logs %v left, purged %v", i, keys, purged)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+/*
+ *
+ *  * Licensed to the Apache Software Foundation (ASF) under one
+ *  * or more contributor license agreements.  See the NOTICE file
+ *  * distributed with this work for additional information
+ *  * regarding copyright ownership.  The ASF licenses this file
+ *  * to you under the Apache License, Version 2.0 (the
+ *  * "License"); you may not use this file except in compliance
+ *  * with the License.  You may obtain a copy of the License at
+ *  *
+ *  *     http://www.apache.org/licenses/LICENSE-2.0
+ *  *
+ *  * Unless required by applicable law or agreed to in writing, software
+ *  * distributed under the License is distributed on an "AS IS" BASIS,
+ *  * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  * See the License for the specific language governing permissions and
+ *  * limitations under the License.
+ *
+ *
+ */
+
+package chaos
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+	"godzilla/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	"math/rand"
+	"sync"
+)
+
+const defaultRunnerNamespace = "test-chaos"
+
+// Runner runs scenarios against a cluster without the server, e.g. from a go test suite. The status
+// of its runs is only kept in memory.
+type Runner struct {
+	client    kubernetes.Interface
+	config    *rest.Config
+	namespace string
+	events    bool
+	// recorder is shared by the runs, it is nil without WithEvents
+	recorder record.EventRecorder
+	onStatus func(runId uint, prev JobStatus, chaosJob ChaosJob)
+}
+
+type RunnerOption func(*Runner)
+
+// WithNamespace sets the namespace of the chaos jobs, test-chaos by default. It needs the service
+// account of the chaos jobs, see Runner.ReadyChaosEnv.
+func WithNamespace(namespace string) RunnerOption {
+	return func(r *Runner) {
+		r.namespace = namespace
+	}
+}
+
+// WithEvents records the ChaosInjected and ChaosReverted kubernetes events on the targets.
+func WithEvents() RunnerOption {
+	return func(r *Runner) {
+		r.events = true
+	}
+}
+
+// WithStatusFunc calls fn with every status transition of a step, prev is the status before.
+func WithStatusFunc(fn func(runId uint, prev JobStatus, chaosJob ChaosJob)) RunnerOption {
+	return func(r *Runner) {
+		r.onStatus = fn
+	}
+}
+
+// NewRunner returns a runner injecting chaos into the cluster of the kube config.
+func NewRunner(config *rest.Config, options ...RunnerOption) (*Runner, error) {
+	kube, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	r := NewRunnerForClient(kube, options...)
+	r.config = config
+	return r, nil
+}
+
+// NewRunnerForClient returns a runner using the client, e.g. a fake clientset.
+func NewRunnerForClient(client kubernetes.Interface, options ...RunnerOption) *Runner {
+	r := &Runner{client: client, namespace: defaultRunnerNamespace}
+	for _, option := range options {
+		option(r)
+	}
+	if r.events {
+		r.recorder = newEventRecorder(client)
+	}
+	return r
+}
+
+// ReadyChaosEnv sets up the service account of the chaos jobs in the namespace of the runner, with the
+// cluster role and binding it needs. The namespace has to exist.
+func (r *Runner) ReadyChaosEnv(ctx context.Context) error {
+	return readyChaosEnv(ctx, r.client, r.namespace)
+}
+
+// Run runs the stages one after another and the steps inside a stage in parallel, the same way the
+// server does, and returns once all steps are finished. The defaults of the steps are filled in,
+// chaosJobs is not modified. Canceling ctx aborts the run, the result then has the aborted verdict.
+func (r *Runner) Run(ctx context.Context, chaosJobs [][]ChaosJob) (RunResult, error) {
+	chaosJobs = copyStages(chaosJobs)
+	overrideConfigOne(chaosJobs)
+	err := preCheck(chaosJobs)
+	if err != nil {
+		return RunResult{}, err
+	}
+	// the id labels the chaos jobs, it only has to be unique among the runs in the namespace
+	runId := uint(rand.Uint32())
+	tracker := &runTracker{runId: runId, chaosJobs: copyStages(chaosJobs), onStatus: r.onStatus}
+	e := &executor{client: r.client, config: r.config, namespace: r.namespace, recorder: r.recorder, report: tracker.report}
+	ctx = withLogFields(withExecutor(ctx, e), logrus.Fields{RunIdField: runId})
+	logFrom(ctx).Info("running scenario")
+	runSteps(ctx, chaosJobs, runId, func(stepCtx context.Context, j *ChaosJob) {
+		j.Run(stepCtx, runId)
+	})
+	steps := tracker.steps()
+	return RunResult{RunId: runId, Finished: true, Verdict: runVerdict(steps), Steps: wireStages(steps)}, nil
+}
+
+// runTracker keeps the status of a run of a Runner.
+type runTracker struct {
+	mu        sync.Mutex
+	runId     uint
+	chaosJobs [][]ChaosJob
+	onStatus  func(runId uint, prev JobStatus, chaosJob ChaosJob)
+}
+
+func (t *runTracker) report(jobStatusId uint, chaosJob ChaosJob) {
+	t.mu.Lock()
+	prev, err := applyStatus(t.chaosJobs, chaosJob)
+	t.mu.Unlock()
+	if err != nil {
+		runLog(jobStatusId).WithField(StepField, chaosJob.Name).Warnf("status update dropped, reason: %s", err.Error())
+		return
+	}
+	if t.onStatus != nil {
+		t.onStatus(t.runId, prev.Status, chaosJob)
+	}
+}
+
+func (t *runTracker) steps() [][]ChaosJob {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return copyStages(t.chaosJobs)
+}
+
+// copyStages copies the steps with their config, a missing config becomes an empty one.
+func copyStages(chaosJobs [][]ChaosJob) [][]ChaosJob {
+	stages := make([][]ChaosJob, len(chaosJobs))
+	for i := range chaosJobs {
+		stages[i] = make([]ChaosJob, len(chaosJobs[i]))
+		for j := range chaosJobs[i] {
+			stages[i][j] = chaosJobs[i][j].copy()
+		}
+	}
+	return stages
+}
+
+// wireStages converts the stages to the type of the responses.
+func wireStages(chaosJobs [][]ChaosJob) [][]types.ChaosJob {
+	stages := make([][]types.ChaosJob, 0, len(chaosJobs))
+	for _, parallelJobs := range chaosJobs {
+		steps := make([]types.ChaosJob, 0, len(parallelJobs))
+		for _, j := range parallelJobs {
+			steps = append(steps, types.ChaosJob(j))
+		}
+		stages = append(stages, steps)
+	}
+	return stages
+}
+
+// copy returns the step with its own config and lists, a missing config becomes an empty one.
+func (chaosJob ChaosJob) copy() ChaosJob {
+	config := make(map[string]string, len(chaosJob.Config))
+	for k, v := range chaosJob.Config {
+		config[k] = v
+	}
+	chaosJob.Config = config
+	chaosJob.TargetPods = append([]string(nil), chaosJob.TargetPods...)
+	chaosJob.JobNames = append([]string(nil), chaosJob.JobNames...)
+	return chaosJob
+}
@@ -0,0 +1,153 @@
+/*
+ *
+ *  * Licensed to the Apache Software Foundation (ASF) under one
+ *  * or more contributor license agreements.  See the NOTICE file
+ *  * distributed with this work for additional information
+ *  * regarding copyright ownership.  The ASF licenses this file
+ *  * to you under the Apache License, Version 2.0 (the
+ *  * "License"); you may not use this file except in compliance
+ *  * with the License.  You may obtain a copy of the License at
+ *  *
+ *  *     http://www.apache.org/licenses/LICENSE-2.0
+ *  *
+ *  * Unless required by applicable law or agreed to in writing, software
+ *  * distributed under the License is distributed on an "AS IS" BASIS,
+ *  * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  * See the License for the specific language governing permissions and
+ *  * limitations under the License.
+ *
+ *
+ */
+package chaos
+
+import (
+	"context"
+	"fmt"
+	"godzilla/types"
+	batchV1 "k8s.io/api/batch/v1"
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// simulateRunners plays the litmus runner of every chaos job created in the namespace: its pod is
+// created and then updated to succeeded until ctx is done, so that the watch of the step sees it
+// whenever it starts.
+func simulateRunners(ctx context.Context, t *testing.T, clientset *fake.Clientset, namespace string) {
+	w, err := clientset.BatchV1().Jobs(namespace).Watch(ctx, metaV1.ListOptions{})
+	if err != nil {
+		t.Errorf("watch jobs failed, reason: %s", err.Error())
+		return
+	}
+	defer w.Stop()
+	var pods []*coreV1.Pod
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-w.ResultChan():
+			if event.Type != watch.Added {
+				continue
+			}
+			job := event.Object.(*batchV1.Job)
+			pod := &coreV1.Pod{
+				ObjectMeta: metaV1.ObjectMeta{Name: job.Name + "-runner", Namespace: namespace, Labels: job.Spec.Template.Labels},
+				Status:     coreV1.PodStatus{Phase: coreV1.PodRunning},
+			}
+			pod, err = clientset.CoreV1().Pods(namespace).Create(ctx, pod, metaV1.CreateOptions{})
+			if err != nil {
+				continue
+			}
+			pods = append(pods, pod)
+		case <-time.After(5 * time.Millisecond):
+			for i, pod := range pods {
+				pod = pod.DeepCopy()
+				pod.Status.Phase = coreV1.PodSucceeded
+				pod.Annotations = map[string]string{"tick": time.Now().String()}
+				if updated, err := clientset.CoreV1().Pods(namespace).Update(ctx, pod, metaV1.UpdateOptions{}); err == nil {
+					pods[i] = updated
+				}
+			}
+		}
+	}
+}
+
+func TestRunnerRun(t *testing.T) {
+	clientset := fake.NewSimpleClientset(readyPod("nginx-1"))
+	var mu sync.Mutex
+	var transitions []string
+	runner := NewRunnerForClient(clientset, WithEvents(), WithStatusFunc(func(runId uint, prev JobStatus, chaosJob ChaosJob) {
+		mu.Lock()
+		defer mu.Unlock()
+		transitions = append(transitions, fmt.Sprintf("%s %s -> %s", chaosJob.Name, prev, chaosJob.Status))
+	}))
+	if runner.recorder == nil {
+		t.Fatal("recorder of the runner = nil, want it created with the runner")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	simulated := make(chan struct{})
+	go func() {
+		defer close(simulated)
+		simulateRunners(ctx, t, clientset, defaultRunnerNamespace)
+	}()
+	// the fake watch only sees the jobs created after it started
+	time.Sleep(10 * time.Millisecond)
+
+	stages := [][]ChaosJob{{{
+		Name:   "kill-nginx",
+		Type:   string(types.LitmusPodDelete),
+		Config: map[string]string{"APP_NAMESPACE": "app", "APP_LABEL": "app=nginx", "TARGET_PODS": "nginx-1"},
+	}}}
+	result, err := runner.Run(ctx, stages)
+	cancel()
+	<-simulated
+	if err != nil {
+		t.Fatalf("Run() = %v, want nil", err)
+	}
+
+	if result.Verdict != string(SuccessStatus) || !result.Finished {
+		t.Errorf("result = %+v, want a finished run with the success verdict", result)
+	}
+	step := result.Steps[0][0]
+	if step.Status != SuccessStatus || len(step.JobNames) != 1 || !reflect.DeepEqual(step.TargetPods, []string{"nginx-1"}) {
+		t.Errorf("step = %+v, want success with 1 job targeting nginx-1", step)
+	}
+	if stages[0][0].Status != "" || len(stages[0][0].Config) != 3 {
+		t.Errorf("stages = %+v, want them unmodified", stages)
+	}
+	mu.Lock()
+	want := []string{"kill-nginx pending -> running", "kill-nginx running -> success"}
+	if !reflect.DeepEqual(transitions, want) {
+		t.Errorf("transitions = %v, want %v", transitions, want)
+	}
+	mu.Unlock()
+	jobs, err := clientset.BatchV1().Jobs(defaultRunnerNamespace).List(context.Background(), metaV1.ListOptions{})
+	if err != nil || len(jobs.Items) != 0 {
+		t.Errorf("jobs left after the run = %v, %v, want none", len(jobs.Items), err)
+	}
+}
+
+// TestRunnerReadyChaosEnv sets up the service account twice, the second time finds it already there.
+func TestRunnerReadyChaosEnv(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	runner := NewRunnerForClient(clientset, WithNamespace("chaos"))
+	for i := 0; i < 2; i++ {
+		if err := runner.ReadyChaosEnv(context.Background()); err != nil {
+			t.Fatalf("ReadyChaosEnv() = %v, want nil", err)
+		}
+	}
+	_, err := clientset.CoreV1().ServiceAccounts("chaos").Get(context.Background(), "chaos-admin", metaV1.GetOptions{})
+	if err != nil {
+		t.Errorf("get service account failed, reason: %s", err.Error())
+	}
+	binding, err := clientset.RbacV1().ClusterRoleBindings().Get(context.Background(), "chaos-admin", metaV1.GetOptions{})
+	if err != nil || binding.Subjects[0].Namespace != "chaos" {
+		t.Errorf("ClusterRoleBinding = %+v, %v, want it bound to the service account in chaos", binding, err)
+	}
+}
@@ -0,0 +1,120 @@
+/*
+ *
+ *  * Licensed to the Apache Software Foundation (ASF) under one
+ *  * or more contributor license agreements.  See the NOTICE file
+ *  * distributed with this work for additional information
+ *  * regarding copyright ownership.  The ASF licenses this file
+ *  * to you under the Apache License, Version 2.0 (the
+ *  * "License"); you may not use this file except in compliance
+ *  * with the License.  You may obtain a copy of the License at
+ *  *
+ *  *     http://www.apache.org/licenses/LICENSE-2.0
+ *  *
+ *  * Unless required by applicable law or agreed to in writing, software
+ *  * distributed under the License is distributed on an "AS IS" BASIS,
+ *  * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  * See the License for the specific language governing permissions and
+ *  * limitations under the License.
+ *
+ *
+ */
+
+package chaos
+
+import (
+	"github.com/gin-gonic/gin"
+	"godzilla/db"
+	"godzilla/types"
+	"gopkg.in/yaml.v2"
+	"net/http"
+	"time"
+)
+
+type ScenarioBody = types.ScenarioBody
+
+// ApplyScenario creates the scenario or replaces the definition of the existing one with the same name,
+// the name in the path of the /api/v1 route wins over the one of the body.
+func (h *Handler) ApplyScenario(c *gin.Context) {
+	body := ScenarioBody{Name: c.Param("name")}
+	if !bindJSON(c, &body) {
+		return
+	}
+	if name := c.Param("name"); name != "" {
+		body.Name = name
+	}
+	var chaosJobs [][]ChaosJob
+	err := yaml.Unmarshal([]byte(body.Definition), &chaosJobs)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, ErrorResponse(YamlUnmarshalError, err))
+		return
+	}
+	err = preCheck(chaosJobs)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, ErrorResponse(InvalidScenario, err))
+		return
+	}
+	s, err := h.scenarios.GetByName(body.Name)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse(MySqlError, err))
+		return
+	}
+	if s.Id == 0 {
+		s = db.Scenario{Name: body.Name, Definition: body.Definition}
+		err = h.scenarios.Add(&s)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse(MySqlSaveError, err))
+			return
+		}
+		c.JSON(http.StatusCreated, NormalResponse(Ok, s))
+		return
+	}
+	s.Definition = body.Definition
+	s.UpdatedAt = time.Now()
+	err = h.scenarios.Update(&s)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse(MySqlSaveError, err))
+		return
+	}
+	c.JSON(http.StatusOK, NormalResponse(Ok, s))
+}
+
+func (h *Handler) GetScenario(c *gin.Context) {
+	s, err := h.scenarios.GetByName(c.Param("name"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse(MySqlError, err))
+		return
+	}
+	if s.Id == 0 {
+		c.AbortWithStatusJSON(http.StatusNotFound, ErrorResponse(ScenarioNotFound, nil))
+		return
+	}
+	c.JSON(http.StatusOK, NormalResponse(Ok, s))
+}
+
+func (h *Handler) ListScenarios(c *gin.Context) {
+	scenarios, err := h.scenarios.List()
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse(MySqlError, err))
+		return
+	}
+	c.JSON(http.StatusOK, NormalResponse(Ok, scenarios))
+}
+
+func (h *Handler) DeleteScenario(c *gin.Context) {
+	name := param(c, "name", "name")
+	s, err := h.scenarios.GetByName(name)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse(MySqlError, err))
+		return
+	}
+	if s.Id == 0 {
+		c.AbortWithStatusJSON(http.StatusNotFound, ErrorResponse(ScenarioNotFound, nil))
+		return
+	}
+	err = h.scenarios.DeleteByName(name)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse(MySqlError, err))
+		return
+	}
+	c.JSON(http.StatusOK, NormalResponse(Ok, name))
+}
@@ -22,32 +22,43 @@
 package chaos
 
 import (
+	"fmt"
 	"github.com/sirupsen/logrus"
 	"godzilla/db"
+	"godzilla/env"
+	"godzilla/types"
 	"gopkg.in/yaml.v2"
+	"os"
+	"sync"
 	"time"
 )
 
-type JobStatus string
+type JobStatus = types.JobStatus
 
 // pending -> running -> success -> failed
 //
 //				     \-> failed
 //	                 \-> unknown
+//	                 \-> aborted
 const (
-	PendingStatus JobStatus = "pending"
-	RunningStatus JobStatus = "running"
-	SuccessStatus JobStatus = "success"
-	FailedStatus  JobStatus = "failed"
-	UnknownStatus JobStatus = "unknown"
+	PendingStatus = types.PendingStatus
+	RunningStatus = types.RunningStatus
+	SuccessStatus = types.SuccessStatus
+	FailedStatus  = types.FailedStatus
+	UnknownStatus = types.UnknownStatus
+	AbortedStatus = types.AbortedStatus
 )
 
+const abortedReason = "run aborted"
+
 var statusChan = make(chan map[uint]ChaosJob, 100)
 
 func statusCheck(prev JobStatus, curr JobStatus) bool {
-	if prev == PendingStatus && (curr == RunningStatus || curr == FailedStatus || curr == UnknownStatus || curr == SuccessStatus) {
+	if prev == PendingStatus && (curr == RunningStatus || curr == FailedStatus || curr == UnknownStatus || curr == SuccessStatus ||
+		curr == AbortedStatus) {
 		return true
-	} else if prev == RunningStatus && (curr == FailedStatus || curr == UnknownStatus || curr == SuccessStatus) {
+	} else if prev == RunningStatus && (curr == FailedStatus || curr == UnknownStatus || curr == SuccessStatus ||
+		curr == AbortedStatus) {
 		return true
 	} else if prev == SuccessStatus && curr == FailedStatus {
 		return true
@@ -55,62 +66,216 @@ func statusCheck(prev JobStatus, curr JobStatus) bool {
 	return false
 }
 
-func StatusWorker() {
+const statusUpdateRetries = 5
+
+func (h *Handler) StatusWorker() {
 	for status := range statusChan {
 		for k, v := range status {
-			jobStatus := db.JobStatus{Base: db.Base{Id: k}}
-			err := jobStatus.GetById()
-			if err != nil {
-				logrus.Errorf("update status failed for id %v, reason: %s", k, err.Error())
-				break
-			}
-			var chaosJobs [][]ChaosJob
-			err = yaml.Unmarshal([]byte(jobStatus.Status), &chaosJobs)
+			prev, chaosJobs, err := h.updateStatus(k, v)
 			if err != nil {
-				logrus.Errorf("update status failed for id %v, reason: %s", k, err.Error())
-				break
+				deadLetter(k, v, err)
+				continue
 			}
-		out:
-			for i := range chaosJobs {
-				for j := range chaosJobs[i] {
-					if chaosJobs[i][j].Name == v.Name {
-						if statusCheck(chaosJobs[i][j].Status, v.Status) {
-							chaosJobs[i][j].Status = v.Status
-							chaosJobs[i][j].FailedReason = v.FailedReason
-							break out
-						}
-					}
+			runLog(k).WithField(StepField, v.Name).Infof("status updated to %s", v.Status)
+			h.recordEvent(k, prev.Status, v)
+			h.watchers.wake(k)
+			h.observeStatus(k, prev, v, chaosJobs)
+		}
+	}
+}
+
+// updateStatus applies the status of one step with compare-and-swap on the run version,
+// the run is read again and the update retried when another writer won the race.
+func (h *Handler) updateStatus(id uint, chaosJob ChaosJob) (prev ChaosJob, chaosJobs [][]ChaosJob, err error) {
+	for attempt := 0; attempt < statusUpdateRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt*100) * time.Millisecond)
+		}
+		var jobStatus db.JobStatus
+		jobStatus, err = h.runs.GetById(id)
+		if err != nil {
+			runLog(id).Warnf("read status failed, attempt %d, reason: %s", attempt+1, err.Error())
+			continue
+		}
+		if jobStatus.Id == 0 {
+			return prev, nil, fmt.Errorf("run %v not found", id)
+		}
+		chaosJobs = nil
+		err = yaml.Unmarshal([]byte(jobStatus.Status), &chaosJobs)
+		if err != nil {
+			return prev, nil, err
+		}
+		prev, err = applyStatus(chaosJobs, chaosJob)
+		if err != nil {
+			return prev, nil, err
+		}
+		data, _ := yaml.Marshal(chaosJobs)
+		jobStatus.UpdatedAt = time.Now()
+		jobStatus.Status = string(data)
+		err = h.runs.UpdateById(&jobStatus)
+		if err == nil {
+			return prev, chaosJobs, nil
+		}
+		runLog(id).Warnf("update status failed, attempt %d, reason: %s", attempt+1, err.Error())
+	}
+	return prev, nil, err
+}
+
+// applyStatus copies the status of chaosJob into its step and returns the step as it was before.
+func applyStatus(chaosJobs [][]ChaosJob, chaosJob ChaosJob) (ChaosJob, error) {
+	for i := range chaosJobs {
+		for j := range chaosJobs[i] {
+			if chaosJobs[i][j].Name == chaosJob.Name {
+				prev := chaosJobs[i][j]
+				if !statusCheck(prev.Status, chaosJob.Status) {
+					return prev, fmt.Errorf("transition of step %s from %s to %s is not allowed",
+						chaosJob.Name, prev.Status, chaosJob.Status)
 				}
+				chaosJobs[i][j].Status = chaosJob.Status
+				chaosJobs[i][j].FailedReason = chaosJob.FailedReason
+				chaosJobs[i][j].TargetPods = chaosJob.TargetPods
+				chaosJobs[i][j].JobNames = chaosJob.JobNames
+				chaosJobs[i][j].UpdatedAt = chaosJob.UpdatedAt
+				return prev, nil
 			}
-			// update status
-			data, _ := yaml.Marshal(chaosJobs)
-			jobStatus.UpdatedAt = time.Now()
-			jobStatus.Status = string(data)
-			err = jobStatus.UpdateById()
-			if err != nil {
-				logrus.Errorf("update status failed for id %v, reason: %s", k, err.Error())
-				break
+		}
+	}
+	return ChaosJob{}, fmt.Errorf("step %s not found", chaosJob.Name)
+}
+
+func (h *Handler) recordEvent(id uint, prev JobStatus, chaosJob ChaosJob) {
+	event := db.JobEvent{
+		CreatedAt:  chaosJob.UpdatedAt,
+		RunId:      id,
+		Step:       chaosJob.Name,
+		FromStatus: string(prev),
+		ToStatus:   string(chaosJob.Status),
+		Reason:     chaosJob.FailedReason,
+		TargetPods: chaosJob.TargetPods,
+		JobNames:   chaosJob.JobNames,
+	}
+	err := h.events.Add(&event)
+	if err != nil {
+		runLog(id).WithField(StepField, chaosJob.Name).Errorf("record event failed, reason: %s", err.Error())
+	}
+}
+
+var (
+	// deadLetterLog is opened by the first dropped status update, so that importing the package opens no file
+	deadLetterLog  *logrus.Logger
+	deadLetterOnce sync.Once
+)
+
+// newDeadLetterLog writes the dropped status updates as json lines into STATUS_DEAD_LETTER_FILE,
+// the default logger is used if the file is not set.
+func newDeadLetterLog() *logrus.Logger {
+	if env.StatusDeadLetterFile == "" {
+		return logrus.StandardLogger()
+	}
+	f, err := os.OpenFile(env.StatusDeadLetterFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		logrus.Errorf("open dead letter file %s failed, reason: %s", env.StatusDeadLetterFile, err.Error())
+		return logrus.StandardLogger()
+	}
+	logger := logrus.New()
+	logger.SetFormatter(&logrus.JSONFormatter{})
+	logger.SetOutput(f)
+	return logger
+}
+
+func deadLetter(id uint, chaosJob ChaosJob, err error) {
+	deadLetterOnce.Do(func() {
+		deadLetterLog = newDeadLetterLog()
+	})
+	deadLetterLog.WithFields(logrus.Fields{
+		"dead_letter":   true,
+		RunIdField:      id,
+		StepField:       chaosJob.Name,
+		"status":        chaosJob.Status,
+		"failed_reason": chaosJob.FailedReason,
+	}).Errorf("status update dropped, reason: %s", err.Error())
+}
+
+func (h *Handler) observeStatus(id uint, prev ChaosJob, chaosJob ChaosJob, chaosJobs [][]ChaosJob) {
+	scenario := h.scenarioOf(id)
+	h.notify(WebhookPayload{
+		Event:      StepStatusEvent,
+		RunId:      id,
+		Scenario:   scenario,
+		Step:       chaosJob.Name,
+		Type:       chaosJob.Type,
+		FromStatus: prev.Status,
+		Status:     chaosJob.Status,
+		Reason:     chaosJob.FailedReason,
+		Timestamp:  chaosJob.UpdatedAt,
+	})
+	h.notifyChat(ChatMessage{Event: StepStatusEvent, RunId: id, Scenario: scenario, Step: &chaosJob, Stages: chaosJobs})
+	annotateStep(id, scenario, prev.Status, chaosJob)
+	if prev.Status != RunningStatus && prev.Status != PendingStatus {
+		return
+	}
+	if prev.Status == RunningStatus && chaosJob.Status != RunningStatus {
+		stepDuration.WithLabelValues(chaosJob.Type, string(chaosJob.Status)).
+			Observe(chaosJob.UpdatedAt.Sub(prev.UpdatedAt).Seconds())
+	}
+	if stepsFinished(chaosJobs) {
+		h.runScenarios.Delete(id)
+		verdict := runVerdict(chaosJobs)
+		runsFinished.WithLabelValues(scenario, verdict).Inc()
+		event := RunFinishedEvent
+		if verdict == string(AbortedStatus) {
+			event = RunAbortedEvent
+		}
+		h.notify(WebhookPayload{Event: event, RunId: id, Scenario: scenario, Verdict: verdict, Timestamp: chaosJob.UpdatedAt})
+		h.notifyChat(ChatMessage{Event: event, RunId: id, Scenario: scenario, Verdict: verdict, Stages: chaosJobs})
+	}
+}
+
+// scenarioOf returns the scenario name of a run started by this instance.
+func (h *Handler) scenarioOf(id uint) string {
+	scenario, ok := h.runScenarios.Load(id)
+	if !ok {
+		return ""
+	}
+	return scenario.(string)
+}
+
+// runVerdict is aborted if one of the steps was aborted, otherwise failed as soon as one of the steps
+// did not succeed.
+func runVerdict(chaosJobs [][]ChaosJob) string {
+	verdict := SuccessStatus
+	for _, parallelJobs := range chaosJobs {
+		for _, j := range parallelJobs {
+			if j.Status == AbortedStatus {
+				return string(AbortedStatus)
+			}
+			if j.Status != SuccessStatus {
+				verdict = FailedStatus
 			}
-			logrus.Infof("status updated for id %v", k)
 		}
 	}
+	return string(verdict)
 }
 
-func initStatus(chaosJobs [][]ChaosJob, scenarioId uint) (statusId uint, err error) {
+func (h *Handler) initStatus(chaosJobs [][]ChaosJob, scenarioId uint, traceId, triggeredBy string) (statusId uint, err error) {
 	jobs, _ := yaml.Marshal(chaosJobs)
 	jobStatus := db.JobStatus{
-		ScenarioId: scenarioId,
-		Status:     string(jobs),
+		ScenarioId:  scenarioId,
+		Status:      string(jobs),
+		TraceId:     traceId,
+		TriggeredBy: triggeredBy,
 	}
-	err = jobStatus.Add()
+	err = h.runs.Add(&jobStatus)
 	return jobStatus.Id, err
 }
 
-func initStatusOne(chaosJobs [][]ChaosJob) (statusId uint, err error) {
+func (h *Handler) initStatusOne(chaosJobs [][]ChaosJob, traceId, triggeredBy string) (statusId uint, err error) {
 	jobs, _ := yaml.Marshal(chaosJobs)
 	jobStatus := db.JobStatus{
-		Status: string(jobs),
+		Status:      string(jobs),
+		TraceId:     traceId,
+		TriggeredBy: triggeredBy,
 	}
-	err = jobStatus.Add()
+	err = h.runs.Add(&jobStatus)
 	return jobStatus.Id, err
 }
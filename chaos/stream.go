@@ -0,0 +1,358 @@
+/*
+ *
+ *  * Licensed to the Apache Software Foundation (ASF) under one
+ *  * or more contributor license agreements.  See the NOTICE file
+ *  * distributed with this work for additional information
+ *  * regarding copyright ownership.  The ASF licenses this file
+ *  * to you under the Apache License, Version 2.0 (the
+ *  * "License"); you may not use this file except in compliance
+ *  * with the License.  You may obtain a copy of the License at
+ *  *
+ *  *     http://www.apache.org/licenses/LICENSE-2.0
+ *  *
+ *  * Unless required by applicable law or agreed to in writing, software
+ *  * distributed under the License is distributed on an "AS IS" BASIS,
+ *  * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  * See the License for the specific language governing permissions and
+ *  * limitations under the License.
+ *
+ *
+ */
+
+package chaos
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"godzilla/db"
+	"godzilla/types"
+	"gopkg.in/yaml.v2"
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// streamPollInterval is how often the streams read the run again, the status worker of this
+	// instance wakes them up earlier for the runs it updates
+	streamPollInterval = 2 * time.Second
+	// streamCatchUpTimeout ends the stream of a finished run even if its last events are never recorded
+	streamCatchUpTimeout = 10 * time.Second
+	lastEventIdHeader    = "Last-Event-ID"
+)
+
+// runWatchers wakes up the streams of a run when its status changes.
+type runWatchers struct {
+	mu    sync.Mutex
+	chans map[uint]map[chan struct{}]bool
+}
+
+func (w *runWatchers) watch(id uint) (chan struct{}, func()) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.chans == nil {
+		w.chans = make(map[uint]map[chan struct{}]bool)
+	}
+	if w.chans[id] == nil {
+		w.chans[id] = make(map[chan struct{}]bool)
+	}
+	ch := make(chan struct{}, 1)
+	w.chans[id][ch] = true
+	return ch, func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		delete(w.chans[id], ch)
+		if len(w.chans[id]) == 0 {
+			delete(w.chans, id)
+		}
+	}
+}
+
+func (w *runWatchers) wake(id uint) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for ch := range w.chans[id] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// RunEnd is the data of the last event of the streams.
+type RunEnd = types.RunEnd
+
+// LogLine is one line of the output of a chaos job pod.
+type LogLine = types.LogLine
+
+func startStream(c *gin.Context) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+}
+
+func renderEvent(c *gin.Context, event sse.Event) {
+	c.Render(-1, event)
+	c.Writer.Flush()
+}
+
+// lastEventId is where a stream resumes, from the Last-Event-ID header sent by reconnecting clients
+// or the lastEventId query.
+func lastEventId(c *gin.Context) string {
+	if id := c.GetHeader(lastEventIdHeader); id != "" {
+		return id
+	}
+	return c.Query("lastEventId")
+}
+
+// StreamEvents sends the status transitions of the run as server-sent events with the event id as id,
+// the stream closes with an end event once the run is finished.
+func (h *Handler) StreamEvents(c *gin.Context) {
+	id, err := idParam(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse(RequestError, err))
+		return
+	}
+	var lastId uint64
+	if last := lastEventId(c); last != "" {
+		lastId, err = strconv.ParseUint(last, 10, 64)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse(RequestError, err))
+			return
+		}
+	}
+	run, err := h.runs.GetById(uint(id))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse(MySqlError, err))
+		return
+	}
+	if run.Id == 0 {
+		c.AbortWithStatusJSON(http.StatusNotFound, ErrorResponse(RunNotFound, nil))
+		return
+	}
+	wake, stop := h.watchers.watch(run.Id)
+	defer stop()
+	ticker := time.NewTicker(streamPollInterval)
+	defer ticker.Stop()
+	startStream(c)
+	var finishedAt time.Time
+	for {
+		events, err := h.events.ListByRunId(run.Id)
+		if err != nil {
+			renderEvent(c, sse.Event{Event: "error", Data: err.Error()})
+			return
+		}
+		for _, event := range events {
+			if uint64(event.Id) > lastId {
+				renderEvent(c, sse.Event{Id: fmt.Sprintf("%v", event.Id), Event: "status", Data: event})
+				lastId = uint64(event.Id)
+			}
+		}
+		run, err = h.runs.GetById(run.Id)
+		if err != nil {
+			renderEvent(c, sse.Event{Event: "error", Data: err.Error()})
+			return
+		}
+		var chaosJobs [][]ChaosJob
+		if run.Id == 0 || yaml.Unmarshal([]byte(run.Status), &chaosJobs) != nil {
+			// purged or unreadable, there is nothing more to wait for
+			renderEvent(c, sse.Event{Event: "end", Data: RunEnd{RunId: uint(id)}})
+			return
+		}
+		if stepsFinished(chaosJobs) {
+			if finishedAt.IsZero() {
+				finishedAt = time.Now()
+			}
+			if eventsCaughtUp(chaosJobs, events) || time.Since(finishedAt) > streamCatchUpTimeout {
+				renderEvent(c, sse.Event{Event: "end", Data: RunEnd{RunId: run.Id, Verdict: runVerdict(chaosJobs)}})
+				return
+			}
+		}
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// eventsCaughtUp reports whether the last event of every step has the status of the step in the run,
+// the status of the run is saved before its event.
+func eventsCaughtUp(chaosJobs [][]ChaosJob, events []db.JobEvent) bool {
+	last := make(map[string]string)
+	for _, event := range events {
+		last[event.Step] = event.ToStatus
+	}
+	for _, parallelJobs := range chaosJobs {
+		for _, j := range parallelJobs {
+			if j.Status != PendingStatus && last[j.Name] != string(j.Status) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// StreamLogs sends the live output of the chaos job pods of the run, or of one of its steps, as
+// server-sent events. The id of the events is the timestamp of the line, a resumed stream skips the
+// lines up to it. The stream closes with an end event once the run is finished, the logs are
+// downloadable from the log store afterwards.
+func (h *Handler) StreamLogs(c *gin.Context) {
+	id, err := idParam(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse(RequestError, err))
+		return
+	}
+	var since time.Time
+	if last := lastEventId(c); last != "" {
+		since, err = time.Parse(time.RFC3339Nano, last)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse(RequestError, err))
+			return
+		}
+	}
+	run, err := h.runs.GetById(uint(id))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse(MySqlError, err))
+		return
+	}
+	if run.Id == 0 {
+		c.AbortWithStatusJSON(http.StatusNotFound, ErrorResponse(RunNotFound, nil))
+		return
+	}
+	selector := fmt.Sprintf("chaos.job.id=%v", run.Id)
+	if step := c.Query("step"); step != "" {
+		selector += ",chaos.job.name=" + step
+	}
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+	kubeCtx, done := kubeCall(ctx, "watch_pods")
+	kube := executorFrom(ctx)
+	w, err := kube.client.CoreV1().Pods(kube.namespace).Watch(kubeCtx, metaV1.ListOptions{LabelSelector: selector})
+	done(err)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse(ChaosJobRunError, err))
+		return
+	}
+	defer w.Stop()
+
+	lines := make(chan LogLine, 100)
+	var streams sync.WaitGroup
+	streamed := make(map[string]bool)
+	wake, stop := h.watchers.watch(run.Id)
+	defer stop()
+	ticker := time.NewTicker(streamPollInterval)
+	defer ticker.Stop()
+	var checkedAt time.Time
+	startStream(c)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case line := <-lines:
+			renderEvent(c, sse.Event{Id: line.Time, Event: "log", Data: line})
+		case event, ok := <-w.ResultChan():
+			if !ok {
+				renderEvent(c, sse.Event{Event: "error", Data: "pod watch closed"})
+				return
+			}
+			pod, isPod := event.Object.(*coreV1.Pod)
+			if !isPod || event.Type == watch.Deleted {
+				continue
+			}
+			for _, cs := range append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...) {
+				key := pod.Name + "/" + cs.Name
+				if streamed[key] || (cs.State.Running == nil && cs.State.Terminated == nil) {
+					continue
+				}
+				streamed[key] = true
+				streams.Add(1)
+				go func(step, podName, container string) {
+					defer streams.Done()
+					followLogs(ctx, step, podName, container, since, lines)
+				}(pod.Labels["chaos.job.name"], pod.Name, cs.Name)
+			}
+		case <-wake:
+		case <-ticker.C:
+		}
+		if time.Since(checkedAt) < time.Second {
+			continue
+		}
+		checkedAt = time.Now()
+		run, err = h.runs.GetById(run.Id)
+		if err != nil {
+			continue
+		}
+		var chaosJobs [][]ChaosJob
+		if run.Id == 0 || yaml.Unmarshal([]byte(run.Status), &chaosJobs) != nil || stepsFinished(chaosJobs) {
+			// the pods are deleted before their step finishes, send what the streams still have
+			drained := make(chan struct{})
+			go func() {
+				streams.Wait()
+				close(drained)
+			}()
+			timeout := time.NewTimer(streamCatchUpTimeout)
+		drain:
+			for {
+				select {
+				case line := <-lines:
+					renderEvent(c, sse.Event{Id: line.Time, Event: "log", Data: line})
+				case <-drained:
+					break drain
+				case <-timeout.C:
+					break drain
+				}
+			}
+			timeout.Stop()
+			for len(lines) > 0 {
+				line := <-lines
+				renderEvent(c, sse.Event{Id: line.Time, Event: "log", Data: line})
+			}
+			renderEvent(c, sse.Event{Event: "end", Data: RunEnd{RunId: uint(id), Verdict: runVerdict(chaosJobs)}})
+			return
+		}
+	}
+}
+
+// followLogs sends the lines of the container written after since until the pod is gone.
+func followLogs(ctx context.Context, step, podName, container string, since time.Time, lines chan<- LogLine) {
+	options := &coreV1.PodLogOptions{Container: container, Follow: true, Timestamps: true}
+	if !since.IsZero() {
+		sinceTime := metaV1.NewTime(since)
+		options.SinceTime = &sinceTime
+	}
+	kubeCtx, done := kubeCall(ctx, "stream_logs", attribute.String("pod", podName))
+	kube := executorFrom(ctx)
+	reader, err := kube.client.CoreV1().Pods(kube.namespace).GetLogs(podName, options).Stream(kubeCtx)
+	done(err)
+	if err != nil {
+		logFrom(ctx).Warnf("stream logs of pod %s container %s failed, reason: %s", podName, container, err.Error())
+		return
+	}
+	defer reader.Close()
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		ts, line, _ := strings.Cut(scanner.Text(), " ")
+		if t, err := time.Parse(time.RFC3339Nano, ts); err == nil && !t.After(since) {
+			continue
+		}
+		select {
+		case lines <- LogLine{Step: step, Pod: podName, Container: container, Time: ts, Line: line}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,75 @@
+/*
+ *
+ *  * Licensed to the Apache Software Foundation (ASF) under one
+ *  * or more contributor license agreements.  See the NOTICE file
+ *  * distributed with this work for additional information
+ *  * regarding copyright ownership.  The ASF licenses this file
+ *  * to you under the Apache License, Version 2.0 (the
+ *  * "License"); you may not use this file except in compliance
+ *  * with the License.  You may obtain a copy of the License at
+ *  *
+ *  *     http://www.apache.org/licenses/LICENSE-2.0
+ *  *
+ *  * Unless required by applicable law or agreed to in writing, software
+ *  * distributed under the License is distributed on an "AS IS" BASIS,
+ *  * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  * See the License for the specific language governing permissions and
+ *  * limitations under the License.
+ *
+ *
+ */
+
+package chaos
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer is taken from the global provider, tests swap it for one of their own provider.
+var tracer = otel.Tracer("godzilla/chaos")
+
+// traceIdHeader returns the trace id of a run from the create endpoints.
+const traceIdHeader = "X-Trace-Id"
+
+// startRunSpan starts the root span of a run, it continues the trace of the request if there is one.
+// The context is detached from the request since the run outlives it.
+func startRunSpan(c *gin.Context, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	ctx := otel.GetTextMapPropagator().Extract(context.Background(), propagation.HeaderCarrier(c.Request.Header))
+	// keep the request id in the logs of the run
+	ctx = withLogFields(ctx, logFieldsOf(c.Request.Context()))
+	return tracer.Start(ctx, "chaos.run", trace.WithAttributes(attrs...))
+}
+
+func traceIdOf(span trace.Span) string {
+	if !span.SpanContext().HasTraceID() {
+		return ""
+	}
+	return span.SpanContext().TraceID().String()
+}
+
+// endSpan records err on the span before ending it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// kubeCall starts the span of a Kubernetes API call, the returned func ends it and counts the failures.
+func kubeCall(ctx context.Context, operation string, attrs ...attribute.KeyValue) (context.Context, func(err error)) {
+	ctx, span := tracer.Start(ctx, "kube."+operation, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...))
+	return ctx, func(err error) {
+		if err != nil {
+			kubeApiErrors.WithLabelValues(operation).Inc()
+		}
+		endSpan(span, err)
+	}
+}
@@ -0,0 +1,80 @@
+/*
+ *
+ *  * Licensed to the Apache Software Foundation (ASF) under one
+ *  * or more contributor license agreements.  See the NOTICE file
+ *  * distributed with this work for additional information
+ *  * regarding copyright ownership.  The ASF licenses this file
+ *  * to you under the Apache License, Version 2.0 (the
+ *  * "License"); you may not use this file except in compliance
+ *  * with the License.  You may obtain a copy of the License at
+ *  *
+ *  *     http://www.apache.org/licenses/LICENSE-2.0
+ *  *
+ *  * Unless required by applicable law or agreed to in writing, software
+ *  * distributed under the License is distributed on an "AS IS" BASIS,
+ *  * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  * See the License for the specific language governing permissions and
+ *  * limitations under the License.
+ *
+ *
+ */
+
+package chaos
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdkTrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRunSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	globalTracer, globalPropagator := tracer, otel.GetTextMapPropagator()
+	t.Cleanup(func() {
+		tracer = globalTracer
+		otel.SetTextMapPropagator(globalPropagator)
+	})
+	tracer = sdkTrace.NewTracerProvider(sdkTrace.WithSpanProcessor(recorder)).Tracer("godzilla/chaos")
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("POST", "/api/v1/runs", nil)
+	c.Request.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	ctx, runSpan := startRunSpan(c)
+	if traceId := traceIdOf(runSpan); traceId != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("trace id of the run = %q, want the one of the request", traceId)
+	}
+	_, done := kubeCall(ctx, "create_job")
+	done(errors.New("forbidden"))
+	endSpan(runSpan, nil)
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want the kube call and the run", len(spans))
+	}
+	kube, run := spans[0], spans[1]
+	if kube.Name() != "kube.create_job" || kube.Parent().SpanID() != run.SpanContext().SpanID() {
+		t.Errorf("span %s has parent %s, want kube.create_job under the run", kube.Name(), kube.Parent().SpanID())
+	}
+	if kube.Status().Code != codes.Error || kube.Status().Description != "forbidden" {
+		t.Errorf("status of the failed kube call = %+v", kube.Status())
+	}
+	if run.Status().Code == codes.Error {
+		t.Errorf("status of the run = %+v, want no error", run.Status())
+	}
+}
+
+func TestTraceIdOfNoTrace(t *testing.T) {
+	if traceId := traceIdOf(trace.SpanFromContext(context.Background())); traceId != "" {
+		t.Errorf("trace id without a trace = %q, want empty", traceId)
+	}
+}
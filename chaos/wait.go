@@ -0,0 +1,203 @@
+/*
+ *
+ *  * Licensed to the Apache Software Foundation (ASF) under one
+ *  * or more contributor license agreements.  See the NOTICE file
+ *  * distributed with this work for additional information
+ *  * regarding copyright ownership.  The ASF licenses this file
+ *  * to you under the Apache License, Version 2.0 (the
+ *  * "License"); you may not use this file except in compliance
+ *  * with the License.  You may obtain a copy of the License at
+ *  *
+ *  *     http://www.apache.org/licenses/LICENSE-2.0
+ *  *
+ *  * Unless required by applicable law or agreed to in writing, software
+ *  * distributed under the License is distributed on an "AS IS" BASIS,
+ *  * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  * See the License for the specific language governing permissions and
+ *  * limitations under the License.
+ *
+ *
+ */
+
+package chaos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"godzilla/env"
+	"godzilla/types"
+	"gopkg.in/yaml.v2"
+	"net/http"
+	"time"
+)
+
+const defaultRunWait = time.Minute
+
+// errRunNotFound is returned by waitRun if the run does not exist, e.g. it was purged while waited for
+var errRunNotFound = errors.New("run not found")
+
+// RunResult is the body of the responses waiting for a run, Verdict is only set once it is finished.
+type RunResult = types.RunResult
+
+// parseWait reads the duration of the query key, capped at RUN_WAIT_MAX. It is zero if the key is absent
+// and fallback is zero.
+func parseWait(c *gin.Context, key string, fallback time.Duration) (time.Duration, error) {
+	wait := fallback
+	if value := c.Query(key); value != "" {
+		var err error
+		wait, err = time.ParseDuration(value)
+		if err != nil {
+			return 0, fmt.Errorf("parse %s failed: %s", key, err.Error())
+		}
+		if wait <= 0 {
+			return 0, fmt.Errorf("%s must be positive", key)
+		}
+	}
+	max, err := time.ParseDuration(env.RunWaitMax)
+	if err != nil {
+		return 0, fmt.Errorf("parse RUN_WAIT_MAX failed: %s", err.Error())
+	}
+	if wait > max {
+		wait = max
+	}
+	return wait, nil
+}
+
+// waitRun blocks until the run is finished, the timeout expires or ctx is done, whichever comes first.
+func (h *Handler) waitRun(ctx context.Context, id uint, timeout time.Duration) (RunResult, error) {
+	wake, stop := h.watchers.watch(id)
+	defer stop()
+	ticker := time.NewTicker(streamPollInterval)
+	defer ticker.Stop()
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	result := RunResult{RunId: id}
+	for {
+		run, err := h.runs.GetById(id)
+		if err != nil {
+			return result, err
+		}
+		if run.Id == 0 {
+			return result, errRunNotFound
+		}
+		var chaosJobs [][]ChaosJob
+		err = yaml.Unmarshal([]byte(run.Status), &chaosJobs)
+		if err != nil {
+			return result, err
+		}
+		result.Steps = wireStages(chaosJobs)
+		if stepsFinished(chaosJobs) {
+			result.Finished = true
+			result.Verdict = runVerdict(chaosJobs)
+			return result, nil
+		}
+		select {
+		case <-ctx.Done():
+			return result, nil
+		case <-deadline.C:
+			return result, nil
+		case <-wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// respondRunResult answers 200 once the run succeeded, 409 if it failed or was aborted and 202 while it
+// is still going on, so that a pipeline step fails with the run.
+func respondRunResult(c *gin.Context, result RunResult) {
+	switch {
+	case !result.Finished:
+		c.JSON(http.StatusAccepted, NormalResponse(RunPending, result))
+	case result.Verdict == string(SuccessStatus):
+		c.JSON(http.StatusOK, NormalResponse(RunSucceeded, result))
+	case result.Verdict == string(AbortedStatus):
+		c.JSON(http.StatusConflict, NormalResponse(RunAborted, result))
+	default:
+		c.JSON(http.StatusConflict, NormalResponse(RunFailed, result))
+	}
+}
+
+// respondWaitError answers the error of waitRun.
+func respondWaitError(c *gin.Context, err error) {
+	if err == errRunNotFound {
+		c.AbortWithStatusJSON(http.StatusNotFound, ErrorResponse(RunNotFound, nil))
+		return
+	}
+	c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse(MySqlError, err))
+}
+
+// WaitChaos long-polls the run until it is finished or the timeout query, one minute by default, expires.
+func (h *Handler) WaitChaos(c *gin.Context) {
+	id, err := idParam(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse(RequestError, err))
+		return
+	}
+	timeout, err := parseWait(c, "timeout", defaultRunWait)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse(RequestError, err))
+		return
+	}
+	run, err := h.runs.GetById(uint(id))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse(MySqlError, err))
+		return
+	}
+	if run.Id == 0 {
+		c.AbortWithStatusJSON(http.StatusNotFound, ErrorResponse(RunNotFound, nil))
+		return
+	}
+	result, err := h.waitRun(c.Request.Context(), run.Id, timeout)
+	if err != nil {
+		respondWaitError(c, err)
+		return
+	}
+	respondRunResult(c, result)
+}
+
+// GetRun returns the current status of the run without waiting.
+func (h *Handler) GetRun(c *gin.Context) {
+	id, err := idParam(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse(RequestError, err))
+		return
+	}
+	run, err := h.runs.GetById(uint(id))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse(MySqlError, err))
+		return
+	}
+	if run.Id == 0 {
+		c.AbortWithStatusJSON(http.StatusNotFound, ErrorResponse(RunNotFound, nil))
+		return
+	}
+	var chaosJobs [][]ChaosJob
+	err = yaml.Unmarshal([]byte(run.Status), &chaosJobs)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse(YamlUnmarshalError, err))
+		return
+	}
+	result := RunResult{RunId: run.Id, Steps: wireStages(chaosJobs)}
+	if stepsFinished(chaosJobs) {
+		result.Finished = true
+		result.Verdict = runVerdict(chaosJobs)
+	}
+	c.JSON(http.StatusOK, NormalResponse(Ok, result))
+}
+
+// respondCreated answers the creation of a run, right away or, with the wait query, once the run is
+// finished or the wait expired.
+func (h *Handler) respondCreated(c *gin.Context, id uint, wait time.Duration) {
+	if wait == 0 {
+		c.JSON(http.StatusCreated, NormalResponse(Ok, id))
+		return
+	}
+	result, err := h.waitRun(c.Request.Context(), id, wait)
+	if err != nil {
+		respondWaitError(c, err)
+		return
+	}
+	respondRunResult(c, result)
+}
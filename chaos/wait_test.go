@@ -0,0 +1,98 @@
+/*
+ *
+ *  * Licensed to the Apache Software Foundation (ASF) under one
+ *  * or more contributor license agreements.  See the NOTICE file
+ *  * distributed with this work for additional information
+ *  * regarding copyright ownership.  The ASF licenses this file
+ *  * to you under the Apache License, Version 2.0 (the
+ *  * "License"); you may not use this file except in compliance
+ *  * with the License.  You may obtain a copy of the License at
+ *  *
+ *  *     http://www.apache.org/licenses/LICENSE-2.0
+ *  *
+ *  * Unless required by applicable law or agreed to in writing, software
+ *  * distributed under the License is distributed on an "AS IS" BASIS,
+ *  * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  * See the License for the specific language governing permissions and
+ *  * limitations under the License.
+ *
+ *
+ */
+package chaos
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"godzilla/db"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWaitChaos(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := NewHandler(db.NewMemoryStore(), nil, nil)
+	router := gin.New()
+	router.GET("/runs/:id/wait", h.WaitChaos)
+	ids := make(map[JobStatus]uint)
+	for _, status := range []JobStatus{SuccessStatus, FailedStatus, AbortedStatus, RunningStatus} {
+		run := db.JobStatus{ScenarioId: 1, Status: runStatus(t, status)}
+		if err := h.runs.Add(&run); err != nil {
+			t.Fatal(err)
+		}
+		ids[status] = run.Id
+	}
+
+	tests := []struct {
+		name       string
+		id         uint
+		wantCode   int
+		wantResult RunResult
+	}{
+		{"succeeded", ids[SuccessStatus], http.StatusOK, RunResult{Finished: true, Verdict: string(SuccessStatus)}},
+		{"failed", ids[FailedStatus], http.StatusConflict, RunResult{Finished: true, Verdict: string(FailedStatus)}},
+		{"aborted", ids[AbortedStatus], http.StatusConflict, RunResult{Finished: true, Verdict: string(AbortedStatus)}},
+		{"running", ids[RunningStatus], http.StatusAccepted, RunResult{}},
+		{"missing", 4242, http.StatusNotFound, RunResult{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/runs/%v/wait?timeout=10ms", tt.id), nil))
+			if w.Code != tt.wantCode {
+				t.Fatalf("status code = %d, want %d, body %s", w.Code, tt.wantCode, w.Body.String())
+			}
+			if w.Code == http.StatusNotFound {
+				return
+			}
+			var resp struct {
+				Body RunResult `json:"body"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Body.RunId != tt.id || resp.Body.Finished != tt.wantResult.Finished || resp.Body.Verdict != tt.wantResult.Verdict {
+				t.Errorf("result = %+v, want finished %v with verdict %q", resp.Body, tt.wantResult.Finished, tt.wantResult.Verdict)
+			}
+		})
+	}
+}
+
+func TestWaitRunPurged(t *testing.T) {
+	h := NewHandler(db.NewMemoryStore(), nil, nil)
+	run := db.JobStatus{ScenarioId: 1, Status: runStatus(t, RunningStatus)}
+	if err := h.runs.Add(&run); err != nil {
+		t.Fatal(err)
+	}
+	time.AfterFunc(20*time.Millisecond, func() {
+		_ = h.runs.DeleteById(run.Id)
+		h.watchers.wake(run.Id)
+	})
+	_, err := h.waitRun(context.Background(), run.Id, time.Minute)
+	if err != errRunNotFound {
+		t.Errorf("waitRun() = %v, want %v", err, errRunNotFound)
+	}
+}
@@ -0,0 +1,292 @@
+/*
+ *
+ *  * Licensed to the Apache Software Foundation (ASF) under one
+ *  * or more contributor license agreements.  See the NOTICE file
+ *  * distributed with this work for additional information
+ *  * regarding copyright ownership.  The ASF licenses this file
+ *  * to you under the Apache License, Version 2.0 (the
+ *  * "License"); you may not use this file except in compliance
+ *  * with the License.  You may obtain a copy of the License at
+ *  *
+ *  *     http://www.apache.org/licenses/LICENSE-2.0
+ *  *
+ *  * Unless required by applicable law or agreed to in writing, software
+ *  * distributed under the License is distributed on an "AS IS" BASIS,
+ *  * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  * See the License for the specific language governing permissions and
+ *  * limitations under the License.
+ *
+ *
+ */
+
+package chaos
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"godzilla/db"
+	"net/http"
+	"time"
+)
+
+const (
+	RunStartedEvent  = "run.started"
+	StepStatusEvent  = "step.status"
+	RunFinishedEvent = "run.finished"
+	RunAbortedEvent  = "run.aborted"
+)
+
+var webhookEvents = map[string]bool{
+	RunStartedEvent:  true,
+	StepStatusEvent:  true,
+	RunFinishedEvent: true,
+	RunAbortedEvent:  true,
+}
+
+const (
+	webhookWorkers     = 4
+	webhookQueueSize   = 1000
+	webhookMaxAttempts = 5
+	webhookTimeout     = 10 * time.Second
+	signatureHeader    = "X-Godzilla-Signature"
+)
+
+// WebhookPayload is the body posted to the webhooks, the step fields are only set for step.status.
+type WebhookPayload struct {
+	Event      string    `json:"event"`
+	RunId      uint      `json:"runId"`
+	Scenario   string    `json:"scenario,omitempty"`
+	Step       string    `json:"step,omitempty"`
+	Type       string    `json:"type,omitempty"`
+	FromStatus JobStatus `json:"fromStatus,omitempty"`
+	Status     JobStatus `json:"status,omitempty"`
+	Reason     string    `json:"reason,omitempty"`
+	Verdict    string    `json:"verdict,omitempty"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+type WebhookBody struct {
+	Url      string   `json:"url" binding:"required,url"`
+	Events   []string `json:"events" binding:"required,min=1"`
+	Scenario string   `json:"scenario,omitempty"`
+	Secret   string   `json:"secret,omitempty"`
+}
+
+type webhookDelivery struct {
+	webhook  db.Webhook
+	delivery db.WebhookDelivery
+}
+
+var webhookClient = &http.Client{Timeout: webhookTimeout}
+
+// webhookBackoff is the wait before the first retry, it doubles with every attempt.
+var webhookBackoff = time.Second
+
+// notify queues the payload for the webhooks, it is called by the StatusWorker so the webhooks are read and
+// the deliveries saved by the WebhookWorker.
+func (h *Handler) notify(payload WebhookPayload) {
+	select {
+	case h.payloads <- payload:
+	default:
+		runLog(payload.RunId).Errorf("event %s dropped, the webhook queue is full", payload.Event)
+	}
+}
+
+// dispatch saves a delivery of the payload for every webhook subscribed to its event and scenario and
+// queues them.
+func (h *Handler) dispatch(payload WebhookPayload) {
+	webhooks, err := h.webhooks.List()
+	if err != nil {
+		runLog(payload.RunId).Errorf("list webhooks failed, event %s, reason: %s", payload.Event, err.Error())
+		return
+	}
+	data, _ := json.Marshal(payload)
+	for _, webhook := range webhooks {
+		if !subscribed(webhook, payload) {
+			continue
+		}
+		delivery := db.WebhookDelivery{
+			WebhookId: webhook.Id,
+			Event:     payload.Event,
+			RunId:     payload.RunId,
+			Payload:   string(data),
+		}
+		err = h.webhooks.AddDelivery(&delivery)
+		if err != nil {
+			logrus.Errorf("save delivery of webhook %v failed, reason: %s", webhook.Id, err.Error())
+			continue
+		}
+		select {
+		case h.deliveries <- webhookDelivery{webhook: webhook, delivery: delivery}:
+		default:
+			delivery.Error = "delivery queue is full"
+			delivery.UpdatedAt = time.Now()
+			_ = h.webhooks.UpdateDelivery(&delivery)
+			logrus.Errorf("delivery %v to webhook %v dropped, the queue is full", delivery.Id, webhook.Id)
+		}
+	}
+}
+
+func subscribed(webhook db.Webhook, payload WebhookPayload) bool {
+	if webhook.Scenario != "" && webhook.Scenario != payload.Scenario {
+		return false
+	}
+	for _, event := range webhook.Events {
+		if event == payload.Event {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookWorker turns the queued events into deliveries and sends them, a failed delivery is queued again
+// with an exponential backoff until it has been attempted webhookMaxAttempts times.
+func (h *Handler) WebhookWorker() {
+	go func() {
+		// one at a time, so that the deliveries keep the order of the events
+		for payload := range h.payloads {
+			h.dispatch(payload)
+		}
+	}()
+	sem := make(chan struct{}, webhookWorkers)
+	for d := range h.deliveries {
+		sem <- struct{}{}
+		d := d
+		go func() {
+			defer func() { <-sem }()
+			h.deliver(d)
+		}()
+	}
+}
+
+func (h *Handler) deliver(d webhookDelivery) {
+	d.delivery.Attempts++
+	d.delivery.StatusCode, d.delivery.Error = 0, ""
+	statusCode, err := postWebhook(d.webhook, d.delivery)
+	d.delivery.StatusCode = statusCode
+	if err != nil {
+		d.delivery.Error = err.Error()
+	} else {
+		d.delivery.Delivered = true
+	}
+	d.delivery.UpdatedAt = time.Now()
+	updateErr := h.webhooks.UpdateDelivery(&d.delivery)
+	if updateErr != nil {
+		logrus.Errorf("update delivery %v failed, reason: %s", d.delivery.Id, updateErr.Error())
+	}
+	if err == nil {
+		return
+	}
+	if d.delivery.Attempts >= webhookMaxAttempts {
+		logrus.Errorf("delivery %v to webhook %v dropped after %d attempts, reason: %s",
+			d.delivery.Id, d.webhook.Id, d.delivery.Attempts, err.Error())
+		return
+	}
+	backoff := time.Duration(1<<(d.delivery.Attempts-1)) * webhookBackoff
+	logrus.Warnf("delivery %v to webhook %v failed, retrying in %v, reason: %s", d.delivery.Id, d.webhook.Id, backoff, err.Error())
+	time.AfterFunc(backoff, func() {
+		h.deliveries <- d
+	})
+}
+
+func postWebhook(webhook db.Webhook, delivery db.WebhookDelivery) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, webhook.Url, bytes.NewBufferString(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Godzilla-Event", delivery.Event)
+	req.Header.Set("X-Godzilla-Delivery", fmt.Sprintf("%v", delivery.Id))
+	if webhook.Secret != "" {
+		req.Header.Set(signatureHeader, "sha256="+sign(webhook.Secret, []byte(delivery.Payload)))
+	}
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// sign is the hex encoded HMAC-SHA256 of the body, receivers compare it with X-Godzilla-Signature.
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (h *Handler) CreateWebhook(c *gin.Context) {
+	var body WebhookBody
+	if !bindJSON(c, &body) {
+		return
+	}
+	var validationErr ValidationError
+	for _, event := range body.Events {
+		if !webhookEvents[event] {
+			validationErr.add("", "events", "unsupported event %s", event)
+		}
+	}
+	err := validationErr.err()
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, ErrorResponse(RequestError, err))
+		return
+	}
+	webhook := db.Webhook{
+		Url:      body.Url,
+		Events:   body.Events,
+		Scenario: body.Scenario,
+		Secret:   body.Secret,
+	}
+	err = h.webhooks.Add(&webhook)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse(MySqlSaveError, err))
+		return
+	}
+	c.JSON(http.StatusCreated, NormalResponse(Ok, webhook))
+}
+
+func (h *Handler) ListWebhooks(c *gin.Context) {
+	webhooks, err := h.webhooks.List()
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse(MySqlError, err))
+		return
+	}
+	c.JSON(http.StatusOK, NormalResponse(Ok, webhooks))
+}
+
+func (h *Handler) DeleteWebhook(c *gin.Context) {
+	id, err := idParam(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse(RequestError, err))
+		return
+	}
+	err = h.webhooks.DeleteById(uint(id))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse(MySqlError, err))
+		return
+	}
+	c.JSON(http.StatusOK, NormalResponse(Ok, id))
+}
+
+func (h *Handler) ListWebhookDeliveries(c *gin.Context) {
+	id, err := idParam(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse(RequestError, err))
+		return
+	}
+	deliveries, err := h.webhooks.ListDeliveries(uint(id))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse(MySqlError, err))
+		return
+	}
+	c.JSON(http.StatusOK, NormalResponse(Ok, deliveries))
+}
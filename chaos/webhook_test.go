@@ -0,0 +1,133 @@
+/*
+ *
+ *  * Licensed to the Apache Software Foundation (ASF) under one
+ *  * or more contributor license agreements.  See the NOTICE file
+ *  * distributed with this work for additional information
+ *  * regarding copyright ownership.  The ASF licenses this file
+ *  * to you under the Apache License, Version 2.0 (the
+ *  * "License"); you may not use this file except in compliance
+ *  * with the License.  You may obtain a copy of the License at
+ *  *
+ *  *     http://www.apache.org/licenses/LICENSE-2.0
+ *  *
+ *  * Unless required by applicable law or agreed to in writing, software
+ *  * distributed under the License is distributed on an "AS IS" BASIS,
+ *  * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  * See the License for the specific language governing permissions and
+ *  * limitations under the License.
+ *
+ *
+ */
+package chaos
+
+import (
+	"godzilla/db"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitDeliveries waits until every delivery of the webhook is delivered or dropped.
+func waitDeliveries(t *testing.T, h *Handler, webhookId uint, want int) []db.WebhookDelivery {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		deliveries, err := h.webhooks.ListDeliveries(webhookId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		done := len(deliveries) == want
+		for _, d := range deliveries {
+			if !d.Delivered && d.Attempts < webhookMaxAttempts {
+				done = false
+			}
+		}
+		if done {
+			return deliveries
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("deliveries of webhook %v = %+v, want %d delivered", webhookId, deliveries, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWebhookSignature(t *testing.T) {
+	signatures := make(chan string, 1)
+	bodies := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		signatures <- r.Header.Get(signatureHeader)
+		bodies <- string(body)
+	}))
+	defer server.Close()
+
+	h := NewHandler(db.NewMemoryStore(), nil, nil)
+	go h.WebhookWorker()
+	webhook := db.Webhook{Url: server.URL, Events: []string{RunFinishedEvent}, Secret: "s3cret"}
+	other := db.Webhook{Url: server.URL, Events: []string{RunStartedEvent}}
+	for _, w := range []*db.Webhook{&webhook, &other} {
+		if err := h.webhooks.Add(w); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h.notify(WebhookPayload{Event: RunFinishedEvent, RunId: 1, Scenario: "nginx", Verdict: "passed", Timestamp: time.Now()})
+
+	deliveries := waitDeliveries(t, h, webhook.Id, 1)
+	if !deliveries[0].Delivered || deliveries[0].Attempts != 1 {
+		t.Errorf("delivery = %+v, want delivered at the first attempt", deliveries[0])
+	}
+	signature, body := <-signatures, <-bodies
+	if want := "sha256=" + sign("s3cret", []byte(body)); signature != want {
+		t.Errorf("signature = %s, want %s", signature, want)
+	}
+	if body != deliveries[0].Payload {
+		t.Errorf("body = %s, want %s", body, deliveries[0].Payload)
+	}
+	if deliveries, _ := h.webhooks.ListDeliveries(other.Id); len(deliveries) != 0 {
+		t.Errorf("deliveries of the webhook not subscribed to %s = %+v, want none", RunFinishedEvent, deliveries)
+	}
+}
+
+func TestWebhookRetries(t *testing.T) {
+	backoff := webhookBackoff
+	webhookBackoff = time.Millisecond
+	defer func() { webhookBackoff = backoff }()
+
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	h := NewHandler(db.NewMemoryStore(), nil, nil)
+	go h.WebhookWorker()
+	webhook := db.Webhook{Url: server.URL, Events: []string{RunStartedEvent}}
+	broken := db.Webhook{Url: failing.URL, Events: []string{RunStartedEvent}}
+	for _, w := range []*db.Webhook{&webhook, &broken} {
+		if err := h.webhooks.Add(w); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h.notify(WebhookPayload{Event: RunStartedEvent, RunId: 1, Scenario: "nginx", Timestamp: time.Now()})
+
+	delivery := waitDeliveries(t, h, webhook.Id, 1)[0]
+	if !delivery.Delivered || delivery.Attempts != 3 || delivery.StatusCode != http.StatusOK || delivery.Error != "" {
+		t.Errorf("delivery = %+v, want delivered at the third attempt", delivery)
+	}
+	dropped := waitDeliveries(t, h, broken.Id, 1)[0]
+	if dropped.Delivered || dropped.Attempts != webhookMaxAttempts || dropped.StatusCode != http.StatusInternalServerError {
+		t.Errorf("delivery = %+v, want dropped after %d attempts", dropped, webhookMaxAttempts)
+	}
+}
@@ -0,0 +1,274 @@
+/*
+ *
+ *  * Licensed to the Apache Software Foundation (ASF) under one
+ *  * or more contributor license agreements.  See the NOTICE file
+ *  * distributed with this work for additional information
+ *  * regarding copyright ownership.  The ASF licenses this file
+ *  * to you under the Apache License, Version 2.0 (the
+ *  * "License"); you may not use this file except in compliance
+ *  * with the License.  You may obtain a copy of the License at
+ *  *
+ *  *     http://www.apache.org/licenses/LICENSE-2.0
+ *  *
+ *  * Unless required by applicable law or agreed to in writing, software
+ *  * distributed under the License is distributed on an "AS IS" BASIS,
+ *  * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  * See the License for the specific language governing permissions and
+ *  * limitations under the License.
+ *
+ *
+ */
+
+// Package client is the typed Go client of the godzilla api, it shares the request and response types
+// of package types with the server and depends on nothing else of it.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"godzilla/types"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Error is an answer of the server with an unexpected status code, Code is the machine-readable
+// code of the server, e.g. invalid_scenario, and Details the fields which are not valid.
+type Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+	Cause      string
+	Details    []types.FieldError
+}
+
+func (e *Error) Error() string {
+	if e.Cause != "" {
+		return fmt.Sprintf("godzilla: %d %s: %s", e.StatusCode, e.Message, e.Cause)
+	}
+	return fmt.Sprintf("godzilla: %d %s", e.StatusCode, e.Message)
+}
+
+// IsNotFound reports whether err is a 404 of the server, e.g. an unknown scenario or run.
+func IsNotFound(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && e.StatusCode == http.StatusNotFound
+}
+
+// IsInvalid reports whether err is a 422 of the server, the request was rejected by the validation.
+func IsInvalid(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && e.StatusCode == http.StatusUnprocessableEntity
+}
+
+type Client struct {
+	server     string
+	httpClient *http.Client
+}
+
+type Option func(*Client)
+
+// WithHTTPClient replaces http.DefaultClient, e.g. to add authentication.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// New returns a client of the server at the address, e.g. http://godzilla:8080.
+func New(server string, options ...Option) *Client {
+	c := &Client{server: strings.TrimSuffix(server, "/"), httpClient: http.DefaultClient}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// do sends the request, an answer with a status code not in ok is returned as *Error.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body any, ok ...int) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	u := c.server + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if len(ok) == 0 {
+		ok = []int{http.StatusOK, http.StatusCreated}
+	}
+	for _, code := range ok {
+		if resp.StatusCode == code {
+			return resp, nil
+		}
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	var r types.Response
+	if json.Unmarshal(data, &r) == nil && r.Message != "" {
+		return nil, &Error{
+			StatusCode: resp.StatusCode,
+			Code:       r.Code,
+			Message:    r.Message,
+			Cause:      r.Cause,
+			Details:    r.Details,
+		}
+	}
+	return nil, &Error{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(data))}
+}
+
+// call sends the request and decodes the body of the json answer into out, it returns the status code.
+func (c *Client) call(ctx context.Context, method, path string, query url.Values, body, out any, ok ...int) (int, error) {
+	resp, err := c.do(ctx, method, path, query, body, ok...)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// the body is decoded into out since it holds a pointer
+	r := types.Response{Body: out}
+	err = json.NewDecoder(resp.Body).Decode(&r)
+	return resp.StatusCode, err
+}
+
+const apiV1 = "/api/v1"
+
+func scenarioPath(name string) string {
+	return apiV1 + "/scenarios/" + url.PathEscape(name)
+}
+
+func runPath(id uint, sub string) string {
+	return fmt.Sprintf("%s/runs/%v%s", apiV1, id, sub)
+}
+
+// ApplyScenario creates the scenario or replaces its definition, created is false for a replacement.
+func (c *Client) ApplyScenario(ctx context.Context, name, definition string) (scenario types.Scenario, created bool, err error) {
+	code, err := c.call(ctx, http.MethodPut, scenarioPath(name), nil, types.ScenarioBody{
+		Name:       name,
+		Definition: definition,
+	}, &scenario)
+	return scenario, code == http.StatusCreated, err
+}
+
+// GetScenario returns the scenario with its yaml definition.
+func (c *Client) GetScenario(ctx context.Context, name string) (scenario types.Scenario, err error) {
+	_, err = c.call(ctx, http.MethodGet, scenarioPath(name), nil, nil, &scenario)
+	return scenario, err
+}
+
+func (c *Client) ListScenarios(ctx context.Context) (scenarios []types.Scenario, err error) {
+	_, err = c.call(ctx, http.MethodGet, apiV1+"/scenarios", nil, nil, &scenarios)
+	return scenarios, err
+}
+
+func (c *Client) DeleteScenario(ctx context.Context, name string) error {
+	_, err := c.call(ctx, http.MethodDelete, scenarioPath(name), nil, nil, nil)
+	return err
+}
+
+// StartRun starts a run of a stored scenario and returns its id.
+func (c *Client) StartRun(ctx context.Context, body types.ChaosBody) (id uint, err error) {
+	_, err = c.call(ctx, http.MethodPost, scenarioPath(body.Scenario)+"/runs", nil, body, &id)
+	return id, err
+}
+
+// StartSteps starts a run of the stages, each a list of parallel steps, without storing a scenario.
+func (c *Client) StartSteps(ctx context.Context, stages [][]types.ChaosJob) (id uint, err error) {
+	_, err = c.call(ctx, http.MethodPost, apiV1+"/runs", nil, stages, &id)
+	return id, err
+}
+
+// DryRun resolves the config, the targets and the chaos job manifests of a run of a stored scenario
+// without creating anything.
+func (c *Client) DryRun(ctx context.Context, body types.ChaosBody) (dryRun types.DryRun, err error) {
+	_, err = c.call(ctx, http.MethodPost, scenarioPath(body.Scenario)+"/dry-run", nil, body, &dryRun)
+	return dryRun, err
+}
+
+// DryRunSteps is DryRun for stages which are not stored as a scenario.
+func (c *Client) DryRunSteps(ctx context.Context, stages [][]types.ChaosJob) (dryRun types.DryRun, err error) {
+	_, err = c.call(ctx, http.MethodPost, apiV1+"/dry-run", nil, stages, &dryRun)
+	return dryRun, err
+}
+
+// GetRun returns the current status of the run.
+func (c *Client) GetRun(ctx context.Context, id uint) (result types.RunResult, err error) {
+	_, err = c.call(ctx, http.MethodGet, runPath(id, ""), nil, nil, &result)
+	return result, err
+}
+
+// WaitRun long-polls the run until it is finished or ctx is done, the verdict is in the result.
+func (c *Client) WaitRun(ctx context.Context, id uint) (result types.RunResult, err error) {
+	for {
+		query := url.Values{"timeout": {time.Minute.String()}}
+		_, err = c.call(ctx, http.MethodGet, runPath(id, "/wait"), query, nil, &result,
+			http.StatusOK, http.StatusAccepted, http.StatusConflict)
+		if err != nil || result.Finished {
+			return result, err
+		}
+	}
+}
+
+func (c *Client) AbortRun(ctx context.Context, id uint) error {
+	_, err := c.call(ctx, http.MethodPost, runPath(id, "/abort"), nil, nil, nil, http.StatusAccepted)
+	return err
+}
+
+// Timeline returns the status transitions of the run recorded so far.
+func (c *Client) Timeline(ctx context.Context, id uint) (events []types.JobEvent, err error) {
+	_, err = c.call(ctx, http.MethodGet, runPath(id, "/timeline"), nil, nil, &events)
+	return events, err
+}
+
+// Report renders the finished run as types.HtmlFormat, types.MarkdownFormat or types.JunitFormat.
+func (c *Client) Report(ctx context.Context, id uint, format string) ([]byte, error) {
+	query := url.Values{"format": {format}}
+	resp, err := c.do(ctx, http.MethodGet, runPath(id, "/report"), query, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+// DownloadLogs returns the tar.gz archive of the chaos job logs of the run, or of one step if step is
+// not empty. The caller closes it.
+func (c *Client) DownloadLogs(ctx context.Context, id uint, step string) (io.ReadCloser, error) {
+	query := url.Values{}
+	if step != "" {
+		query.Set("step", step)
+	}
+	resp, err := c.do(ctx, http.MethodGet, runPath(id, "/logs/archive"), query, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// DownloadEvidence returns the tar.gz archive of the evidence of the run. The caller closes it.
+func (c *Client) DownloadEvidence(ctx context.Context, id uint) (io.ReadCloser, error) {
+	resp, err := c.do(ctx, http.MethodGet, runPath(id, "/evidence"), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
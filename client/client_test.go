@@ -0,0 +1,129 @@
+/*
+ *
+ *  * Licensed to the Apache Software Foundation (ASF) under one
+ *  * or more contributor license agreements.  See the NOTICE file
+ *  * distributed with this work for additional information
+ *  * regarding copyright ownership.  The ASF licenses this file
+ *  * to you under the Apache License, Version 2.0 (the
+ *  * "License"); you may not use this file except in compliance
+ *  * with the License.  You may obtain a copy of the License at
+ *  *
+ *  *     http://www.apache.org/licenses/LICENSE-2.0
+ *  *
+ *  * Unless required by applicable law or agreed to in writing, software
+ *  * distributed under the License is distributed on an "AS IS" BASIS,
+ *  * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  * See the License for the specific language governing permissions and
+ *  * limitations under the License.
+ *
+ *
+ */
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"godzilla/types"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// newServer answers the requests with handler, writeResponse helps it answer like the server.
+func newServer(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return New(server.URL + "/")
+}
+
+func writeResponse(w http.ResponseWriter, code int, resp types.Response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func TestApplyScenario(t *testing.T) {
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		var body types.ScenarioBody
+		if r.Method != http.MethodPut || r.URL.Path != "/api/v1/scenarios/kill nginx" {
+			http.Error(w, "unexpected "+r.Method+" "+r.URL.Path, http.StatusBadRequest)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeResponse(w, http.StatusCreated, types.Response{
+			Status:  types.NormalStatus,
+			Message: "ok",
+			Body:    types.Scenario{Id: 1, Name: body.Name, Definition: body.Definition},
+		})
+	})
+
+	scenario, created, err := c.ApplyScenario(context.Background(), "kill nginx", "- - name: step")
+	want := types.Scenario{Id: 1, Name: "kill nginx", Definition: "- - name: step"}
+	if err != nil || !created || scenario != want {
+		t.Errorf("ApplyScenario() = %+v, %v, %v, want %+v, true, nil", scenario, created, err, want)
+	}
+}
+
+func TestStartSteps(t *testing.T) {
+	stages := [][]types.ChaosJob{{{Name: "step", Type: "litmus-pod-delete", Config: map[string]string{"APP_NAMESPACE": "app"}}}}
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		var body [][]types.ChaosJob
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || !reflect.DeepEqual(body, stages) {
+			http.Error(w, "unexpected stages", http.StatusBadRequest)
+			return
+		}
+		writeResponse(w, http.StatusCreated, types.Response{Status: types.NormalStatus, Message: "ok", Body: 42})
+	})
+
+	id, err := c.StartSteps(context.Background(), stages)
+	if err != nil || id != 42 {
+		t.Errorf("StartSteps() = %v, %v, want 42, nil", id, err)
+	}
+}
+
+// TestWaitRun polls again while the run is going on and takes the 409 of a failed run as its verdict.
+func TestWaitRun(t *testing.T) {
+	polls := 0
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		polls++
+		if polls == 1 {
+			writeResponse(w, http.StatusAccepted, types.Response{Status: types.NormalStatus, Message: "run pending",
+				Body: types.RunResult{RunId: 7}})
+			return
+		}
+		writeResponse(w, http.StatusConflict, types.Response{Status: types.NormalStatus, Message: "run failed",
+			Body: types.RunResult{RunId: 7, Finished: true, Verdict: string(types.FailedStatus)}})
+	})
+
+	result, err := c.WaitRun(context.Background(), 7)
+	if err != nil || !result.Finished || result.Verdict != string(types.FailedStatus) || polls != 2 {
+		t.Errorf("WaitRun() = %+v, %v after %d polls, want the failed verdict after 2", result, err, polls)
+	}
+}
+
+func TestError(t *testing.T) {
+	details := []types.FieldError{{Step: "step", Field: "config.PODS_AFFECTED_PERC", Message: "out of range"}}
+	c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		writeResponse(w, http.StatusUnprocessableEntity, types.Response{
+			Status:  types.ErrorStatus,
+			Message: "invalid scenario",
+			Code:    "invalid_scenario",
+			Cause:   "out of range",
+			Details: details,
+		})
+	})
+
+	_, err := c.StartSteps(context.Background(), nil)
+	if !IsInvalid(err) || IsNotFound(err) {
+		t.Fatalf("StartSteps() = %v, want a 422 *Error", err)
+	}
+	e := err.(*Error)
+	if e.Code != "invalid_scenario" || e.Cause != "out of range" || !reflect.DeepEqual(e.Details, details) {
+		t.Errorf("Error = %+v, want the code, cause and details of the response", e)
+	}
+}
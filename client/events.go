@@ -0,0 +1,148 @@
+/*
+ *
+ *  * Licensed to the Apache Software Foundation (ASF) under one
+ *  * or more contributor license agreements.  See the NOTICE file
+ *  * distributed with this work for additional information
+ *  * regarding copyright ownership.  The ASF licenses this file
+ *  * to you under the Apache License, Version 2.0 (the
+ *  * "License"); you may not use this file except in compliance
+ *  * with the License.  You may obtain a copy of the License at
+ *  *
+ *  *     http://www.apache.org/licenses/LICENSE-2.0
+ *  *
+ *  * Unless required by applicable law or agreed to in writing, software
+ *  * distributed under the License is distributed on an "AS IS" BASIS,
+ *  * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  * See the License for the specific language governing permissions and
+ *  * limitations under the License.
+ *
+ *
+ */
+
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"godzilla/types"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const watchRetries = 5
+
+type event struct {
+	id    string
+	event string
+	data  string
+}
+
+// readEvents calls fn with each server-sent event of r until r ends or fn returns false.
+func readEvents(r io.Reader, fn func(e event) bool) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	var e event
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if e.event != "" || e.data != "" {
+				if !fn(e) {
+					return nil
+				}
+			}
+			e = event{}
+			continue
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "id":
+			e.id = value
+		case "event":
+			e.event = value
+		case "data":
+			if e.data != "" {
+				e.data += "\n"
+			}
+			e.data += value
+		}
+	}
+	return scanner.Err()
+}
+
+// WatchRun calls fn with every status transition of the run until it is finished, a broken stream
+// is resumed from the last transition. It returns the verdict of the run.
+func (c *Client) WatchRun(ctx context.Context, id uint, fn func(types.JobEvent)) (types.RunEnd, error) {
+	var lastId string
+	for attempt := 0; ; attempt++ {
+		query := url.Values{}
+		if lastId != "" {
+			query.Set("lastEventId", lastId)
+		}
+		resp, err := c.do(ctx, http.MethodGet, runPath(id, "/events"), query, nil)
+		if err != nil {
+			return types.RunEnd{}, err
+		}
+		var end *types.RunEnd
+		err = readEvents(resp.Body, func(e event) bool {
+			switch e.event {
+			case "status":
+				var je types.JobEvent
+				if json.Unmarshal([]byte(e.data), &je) == nil {
+					fn(je)
+				}
+				lastId = e.id
+			case "end":
+				end = &types.RunEnd{}
+				_ = json.Unmarshal([]byte(e.data), end)
+				return false
+			}
+			return true
+		})
+		resp.Body.Close()
+		if end != nil {
+			return *end, nil
+		}
+		if ctx.Err() != nil {
+			return types.RunEnd{}, ctx.Err()
+		}
+		if attempt >= watchRetries {
+			if err == nil {
+				err = errors.New("godzilla: the event stream closed before the run finished")
+			}
+			return types.RunEnd{}, err
+		}
+		time.Sleep(time.Duration(attempt+1) * time.Second)
+	}
+}
+
+// FollowLogs calls fn with the live output of the chaos jobs of the run, or of one step if step is
+// not empty, until the run is finished.
+func (c *Client) FollowLogs(ctx context.Context, id uint, step string, fn func(types.LogLine)) error {
+	query := url.Values{}
+	if step != "" {
+		query.Set("step", step)
+	}
+	resp, err := c.do(ctx, http.MethodGet, runPath(id, "/logs/stream"), query, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return readEvents(resp.Body, func(e event) bool {
+		switch e.event {
+		case "log":
+			var line types.LogLine
+			if json.Unmarshal([]byte(e.data), &line) == nil {
+				fn(line)
+			}
+		case "end":
+			return false
+		}
+		return true
+	})
+}
@@ -0,0 +1,427 @@
+/*
+ *
+ *  * Licensed to the Apache Software Foundation (ASF) under one
+ *  * or more contributor license agreements.  See the NOTICE file
+ *  * distributed with this work for additional information
+ *  * regarding copyright ownership.  The ASF licenses this file
+ *  * to you under the Apache License, Version 2.0 (the
+ *  * "License"); you may not use this file except in compliance
+ *  * with the License.  You may obtain a copy of the License at
+ *  *
+ *  *     http://www.apache.org/licenses/LICENSE-2.0
+ *  *
+ *  * Unless required by applicable law or agreed to in writing, software
+ *  * distributed under the License is distributed on an "AS IS" BASIS,
+ *  * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  * See the License for the specific language governing permissions and
+ *  * limitations under the License.
+ *
+ *
+ */
+
+// Command godzillactl manages the scenarios and runs of a godzilla server.
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"godzilla/client"
+	"godzilla/types"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const usage = `usage: godzillactl [-server URL] <command> [flags] [args]
+
+commands:
+  scenario apply -f FILE [-name NAME]   create or replace a scenario from a yaml file
+  scenario get NAME                     print the definition of a scenario
+  scenario list                         list the scenarios
+  scenario delete NAME                  delete a scenario
+  run start SCENARIO [-set STEP-KEY=VALUE]... [-triggered-by WHO] [-watch]
+                                        start a run, -watch follows it until it is finished
+  run get ID                            print the status of a run
+  run watch ID                          follow the status transitions of a run until it is finished
+  run logs ID [-step STEP] [-follow] [-o FILE]
+                                        download the chaos job logs, or follow them live
+  run report ID [-format html|markdown|junit] [-o FILE]
+                                        render the report of a finished run
+  run abort ID                          abort a run
+
+The server defaults to $GODZILLA_SERVER or http://localhost:8080. Watching a run exits with 3 if the
+run failed and 4 if it was aborted.
+`
+
+const (
+	exitError   = 1
+	exitUsage   = 2
+	exitFailed  = 3
+	exitAborted = 4
+)
+
+// exitCode ends the command with the code without printing an error.
+type exitCode int
+
+func (e exitCode) Error() string {
+	return fmt.Sprintf("exit code %d", int(e))
+}
+
+var errUsage = errors.New("invalid usage")
+
+// stringsFlag collects a repeated flag.
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringsFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+func main() {
+	global := flag.NewFlagSet("godzillactl", flag.ContinueOnError)
+	server := global.String("server", envOr("GODZILLA_SERVER", "http://localhost:8080"), "address of the godzilla server")
+	global.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+	}
+	if global.Parse(os.Args[1:]) != nil {
+		os.Exit(exitUsage)
+	}
+	args := global.Args()
+	if len(args) < 2 {
+		global.Usage()
+		os.Exit(exitUsage)
+	}
+	c := client.New(*server)
+	commands := map[string]func(*client.Client, []string) error{
+		"scenario apply":  applyScenario,
+		"scenario get":    getScenario,
+		"scenario list":   listScenarios,
+		"scenario delete": deleteScenario,
+		"run start":       startRun,
+		"run get":         getRun,
+		"run watch":       watchRun,
+		"run logs":        runLogs,
+		"run report":      runReport,
+		"run abort":       abortRun,
+	}
+	command, ok := commands[args[0]+" "+args[1]]
+	if !ok {
+		global.Usage()
+		os.Exit(exitUsage)
+	}
+	err := command(c, args[2:])
+	var code exitCode
+	switch {
+	case err == nil:
+	case errors.As(err, &code):
+		os.Exit(int(code))
+	case errors.Is(err, errUsage):
+		global.Usage()
+		os.Exit(exitUsage)
+	default:
+		fmt.Fprintf(os.Stderr, "godzillactl: %s\n", err.Error())
+		os.Exit(exitError)
+	}
+}
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// parseFlags parses the flags of a command and checks the number of positional arguments.
+func parseFlags(fs *flag.FlagSet, args []string, positional int) ([]string, error) {
+	fs.SetOutput(io.Discard)
+	err := fs.Parse(args)
+	if err != nil || fs.NArg() != positional {
+		return nil, errUsage
+	}
+	return fs.Args(), nil
+}
+
+func applyScenario(c *client.Client, args []string) error {
+	fs := flag.NewFlagSet("scenario apply", flag.ContinueOnError)
+	file := fs.String("f", "", "yaml file of the scenario")
+	name := fs.String("name", "", "name of the scenario, the file name without extension by default")
+	_, err := parseFlags(fs, args, 0)
+	if err != nil || *file == "" {
+		return errUsage
+	}
+	data, err := os.ReadFile(*file)
+	if err != nil {
+		return err
+	}
+	if *name == "" {
+		*name = strings.TrimSuffix(filepath.Base(*file), filepath.Ext(*file))
+	}
+	_, created, err := c.ApplyScenario(context.Background(), *name, string(data))
+	if err != nil {
+		return err
+	}
+	if created {
+		fmt.Printf("scenario %s created\n", *name)
+	} else {
+		fmt.Printf("scenario %s configured\n", *name)
+	}
+	return nil
+}
+
+func getScenario(c *client.Client, args []string) error {
+	args, err := parseFlags(flag.NewFlagSet("scenario get", flag.ContinueOnError), args, 1)
+	if err != nil {
+		return err
+	}
+	s, err := c.GetScenario(context.Background(), args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Print(s.Definition)
+	return nil
+}
+
+func listScenarios(c *client.Client, args []string) error {
+	_, err := parseFlags(flag.NewFlagSet("scenario list", flag.ContinueOnError), args, 0)
+	if err != nil {
+		return err
+	}
+	scenarios, err := c.ListScenarios(context.Background())
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tUPDATED")
+	for _, s := range scenarios {
+		fmt.Fprintf(w, "%s\t%s\n", s.Name, s.UpdatedAt.Local().Format(time.RFC3339))
+	}
+	return w.Flush()
+}
+
+func deleteScenario(c *client.Client, args []string) error {
+	args, err := parseFlags(flag.NewFlagSet("scenario delete", flag.ContinueOnError), args, 1)
+	if err != nil {
+		return err
+	}
+	err = c.DeleteScenario(context.Background(), args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Printf("scenario %s deleted\n", args[0])
+	return nil
+}
+
+func startRun(c *client.Client, args []string) error {
+	fs := flag.NewFlagSet("run start", flag.ContinueOnError)
+	var params stringsFlag
+	fs.Var(&params, "set", "override a step config, STEP-KEY=VALUE, repeatable")
+	triggeredBy := fs.String("triggered-by", os.Getenv("USER"), "who started the run")
+	follow := fs.Bool("watch", false, "follow the run until it is finished")
+	// flags may follow the scenario name
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		args = append(args[1:], args[0])
+	}
+	args, err := parseFlags(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	overridden := make(map[string]string)
+	for _, param := range params {
+		k, v, ok := strings.Cut(param, "=")
+		if !ok {
+			return fmt.Errorf("invalid parameter %s, expected STEP-KEY=VALUE", param)
+		}
+		overridden[k] = v
+	}
+	id, err := c.StartRun(context.Background(), types.ChaosBody{
+		Scenario:         args[0],
+		OverriddenConfig: overridden,
+		TriggeredBy:      *triggeredBy,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("run %v started\n", id)
+	if !*follow {
+		return nil
+	}
+	return watch(c, id)
+}
+
+func getRun(c *client.Client, args []string) error {
+	args, err := parseFlags(flag.NewFlagSet("run get", flag.ContinueOnError), args, 1)
+	if err != nil {
+		return err
+	}
+	id, err := parseId(args[0])
+	if err != nil {
+		return err
+	}
+	result, err := c.GetRun(context.Background(), id)
+	if err != nil {
+		return err
+	}
+	verdict := "running"
+	if result.Finished {
+		verdict = result.Verdict
+	}
+	fmt.Printf("run %v: %s\n\n", result.RunId, verdict)
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "STAGE\tSTEP\tTYPE\tSTATUS\tTARGETS\tREASON")
+	for i, parallelJobs := range result.Steps {
+		for _, j := range parallelJobs {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", i+1, j.Name, j.Type, j.Status,
+				strings.Join(j.TargetPods, ","), j.FailedReason)
+		}
+	}
+	return w.Flush()
+}
+
+func watchRun(c *client.Client, args []string) error {
+	args, err := parseFlags(flag.NewFlagSet("run watch", flag.ContinueOnError), args, 1)
+	if err != nil {
+		return err
+	}
+	id, err := parseId(args[0])
+	if err != nil {
+		return err
+	}
+	return watch(c, id)
+}
+
+func parseId(arg string) (uint, error) {
+	var id uint
+	_, err := fmt.Sscan(arg, &id)
+	if err != nil {
+		return 0, fmt.Errorf("invalid run id %s", arg)
+	}
+	return id, nil
+}
+
+// watch prints the status transitions of the run and ends with the exit code of the verdict.
+func watch(c *client.Client, id uint) error {
+	end, err := c.WatchRun(context.Background(), id, func(je types.JobEvent) {
+		line := fmt.Sprintf("%s  %-20s %s -> %s", je.CreatedAt.Local().Format("15:04:05"), je.Step,
+			je.FromStatus, je.ToStatus)
+		if je.Reason != "" {
+			line += ": " + je.Reason
+		}
+		fmt.Println(line)
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("run %v finished: %s\n", id, end.Verdict)
+	return verdictCode(end.Verdict)
+}
+
+func verdictCode(verdict string) error {
+	switch verdict {
+	case string(types.SuccessStatus):
+		return nil
+	case string(types.AbortedStatus):
+		return exitCode(exitAborted)
+	default:
+		return exitCode(exitFailed)
+	}
+}
+
+func runLogs(c *client.Client, args []string) error {
+	fs := flag.NewFlagSet("run logs", flag.ContinueOnError)
+	stepName := fs.String("step", "", "only the logs of this step")
+	follow := fs.Bool("follow", false, "print the live output of the chaos jobs")
+	output := fs.String("o", "", "file of the tar.gz archive, run-ID-logs.tar.gz by default")
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		args = append(args[1:], args[0])
+	}
+	args, err := parseFlags(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	id, err := parseId(args[0])
+	if err != nil {
+		return err
+	}
+	if *follow {
+		return c.FollowLogs(context.Background(), id, *stepName, func(line types.LogLine) {
+			fmt.Printf("[%s/%s] %s\n", line.Pod, line.Container, line.Line)
+		})
+	}
+	if *output == "" {
+		*output = fmt.Sprintf("run-%v-logs.tar.gz", id)
+	}
+	archive, err := c.DownloadLogs(context.Background(), id, *stepName)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+	return write(archive, *output)
+}
+
+func runReport(c *client.Client, args []string) error {
+	fs := flag.NewFlagSet("run report", flag.ContinueOnError)
+	format := fs.String("format", "markdown", "html, markdown or junit")
+	output := fs.String("o", "", "file of the report, stdout by default")
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		args = append(args[1:], args[0])
+	}
+	args, err := parseFlags(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	id, err := parseId(args[0])
+	if err != nil {
+		return err
+	}
+	report, err := c.Report(context.Background(), id, *format)
+	if err != nil {
+		return err
+	}
+	return write(bytes.NewReader(report), *output)
+}
+
+// write copies r into the file, or to stdout if output is empty.
+func write(r io.Reader, output string) error {
+	if output == "" {
+		_, err := io.Copy(os.Stdout, r)
+		return err
+	}
+	f, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(f, r)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("written to %s\n", output)
+	return f.Close()
+}
+
+func abortRun(c *client.Client, args []string) error {
+	args, err := parseFlags(flag.NewFlagSet("run abort", flag.ContinueOnError), args, 1)
+	if err != nil {
+		return err
+	}
+	id, err := parseId(args[0])
+	if err != nil {
+		return err
+	}
+	err = c.AbortRun(context.Background(), id)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("run %v aborting\n", id)
+	return nil
+}
@@ -23,19 +23,36 @@ package core
 
 import (
 	"github.com/sirupsen/logrus"
+	"godzilla/env"
 	"path"
 	"runtime"
 	"strconv"
+	"strings"
 )
 
+// InitLogrus sets up the format of LOG_FORMAT, text or json, and the level of LOG_LEVEL.
 func InitLogrus() {
-	customFormatter := new(logrus.TextFormatter)
-	customFormatter.TimestampFormat = "2006-01-02 15:04:05"
-	customFormatter.FullTimestamp = true
-	customFormatter.CallerPrettyfier = func(frame *runtime.Frame) (function string, file string) {
+	callerPrettyfier := func(frame *runtime.Frame) (function string, file string) {
 		fileName := path.Base(frame.File) + ":" + strconv.Itoa(frame.Line)
 		return "", fileName
 	}
-	logrus.SetFormatter(customFormatter)
+	switch strings.ToLower(env.LogFormat) {
+	case "json":
+		logrus.SetFormatter(&logrus.JSONFormatter{
+			TimestampFormat:  "2006-01-02T15:04:05.000Z07:00",
+			CallerPrettyfier: callerPrettyfier,
+		})
+	default:
+		customFormatter := new(logrus.TextFormatter)
+		customFormatter.TimestampFormat = "2006-01-02 15:04:05"
+		customFormatter.FullTimestamp = true
+		customFormatter.CallerPrettyfier = callerPrettyfier
+		logrus.SetFormatter(customFormatter)
+	}
+	level, err := logrus.ParseLevel(env.LogLevel)
+	if err != nil {
+		logrus.Fatalf("parse LOG_LEVEL failed, reason: %s", err.Error())
+	}
+	logrus.SetLevel(level)
 	logrus.SetReportCaller(true)
 }
@@ -0,0 +1,77 @@
+/*
+ *
+ *  * Licensed to the Apache Software Foundation (ASF) under one
+ *  * or more contributor license agreements.  See the NOTICE file
+ *  * distributed with this work for additional information
+ *  * regarding copyright ownership.  The ASF licenses this file
+ *  * to you under the Apache License, Version 2.0 (the
+ *  * "License"); you may not use this file except in compliance
+ *  * with the License.  You may obtain a copy of the License at
+ *  *
+ *  *     http://www.apache.org/licenses/LICENSE-2.0
+ *  *
+ *  * Unless required by applicable law or agreed to in writing, software
+ *  * distributed under the License is distributed on an "AS IS" BASIS,
+ *  * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  * See the License for the specific language governing permissions and
+ *  * limitations under the License.
+ *
+ *
+ */
+
+package core
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"godzilla/chaos"
+	"time"
+)
+
+const requestIdHeader = "X-Request-Id"
+
+// RequestId takes the id of the request from X-Request-Id or generates one, it is sent back in the
+// response and logged by the runs the request starts.
+func RequestId() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestId := c.GetHeader(requestIdHeader)
+		if requestId == "" {
+			b := make([]byte, 16)
+			_, _ = rand.Read(b)
+			requestId = hex.EncodeToString(b)
+		}
+		c.Set(chaos.RequestIdField, requestId)
+		c.Header(requestIdHeader, requestId)
+		c.Request = c.Request.WithContext(chaos.WithRequestId(c.Request.Context(), requestId))
+		c.Next()
+	}
+}
+
+// AccessLog logs the requests with logrus, so they follow LOG_FORMAT and carry the request id.
+func AccessLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		logrus.WithFields(logrus.Fields{
+			chaos.RequestIdField: c.GetString(chaos.RequestIdField),
+			"method":             c.Request.Method,
+			"path":               c.Request.URL.Path,
+			"status":             c.Writer.Status(),
+			"latency":            time.Since(start).String(),
+			"client_ip":          c.ClientIP(),
+		}).Info("request handled")
+	}
+}
+
+// Deprecated marks the responses of a route kept for compatibility, successor is the /api/v1 route
+// replacing it.
+func Deprecated(successor string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Deprecation", "true")
+		c.Header("Link", fmt.Sprintf("<%s>; rel=\"successor-version\"", successor))
+		c.Next()
+	}
+}
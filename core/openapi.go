@@ -0,0 +1,49 @@
+/*
+ *
+ *  * Licensed to the Apache Software Foundation (ASF) under one
+ *  * or more contributor license agreements.  See the NOTICE file
+ *  * distributed with this work for additional information
+ *  * regarding copyright ownership.  The ASF licenses this file
+ *  * to you under the Apache License, Version 2.0 (the
+ *  * "License"); you may not use this file except in compliance
+ *  * with the License.  You may obtain a copy of the License at
+ *  *
+ *  *     http://www.apache.org/licenses/LICENSE-2.0
+ *  *
+ *  * Unless required by applicable law or agreed to in writing, software
+ *  * distributed under the License is distributed on an "AS IS" BASIS,
+ *  * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  * See the License for the specific language governing permissions and
+ *  * limitations under the License.
+ *
+ *
+ */
+
+package core
+
+import (
+	_ "embed"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"sigs.k8s.io/yaml"
+)
+
+// openApiDocument describes the /api/v1 routes, TestOpenApi keeps both in line.
+//
+//go:embed openapi.yaml
+var openApiDocument []byte
+
+const apiV1 = "/api/v1"
+
+func OpenApiYaml(c *gin.Context) {
+	c.Data(http.StatusOK, "application/yaml; charset=utf-8", openApiDocument)
+}
+
+func OpenApiJson(c *gin.Context) {
+	data, err := yaml.YAMLToJSON(openApiDocument)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", data)
+}
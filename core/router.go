@@ -24,17 +24,85 @@ package core
 import (
 	"github.com/gin-contrib/pprof"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"godzilla/chaos"
 )
 
-func SetupRouter() *gin.Engine {
-	router := gin.Default()
+func SetupRouter(h *chaos.Handler) *gin.Engine {
+	router := gin.New()
+	router.Use(RequestId(), AccessLog(), gin.Recovery())
 	pprof.Register(router)
+	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
 
+	v1 := router.Group("/api/v1")
+
+	v1.GET("/openapi.yaml", OpenApiYaml)
+	v1.GET("/openapi.json", OpenApiJson)
+
+	v1.GET("/scenarios", h.ListScenarios)
+	v1.GET("/scenarios/:name", h.GetScenario)
+	v1.PUT("/scenarios/:name", h.ApplyScenario)
+	v1.DELETE("/scenarios/:name", h.DeleteScenario)
+	v1.POST("/scenarios/:name/runs", h.CreateChaos)
+	v1.POST("/scenarios/:name/dry-run", h.DryRunChaos)
+
+	v1.POST("/dry-run", h.DryRunChaosOne)
+
+	v1.POST("/runs", h.CreateChaosOne)
+	v1.GET("/runs/:id", h.GetRun)
+	v1.DELETE("/runs/:id", h.PurgeRun)
+	v1.POST("/runs/:id/abort", h.AbortChaos)
+	v1.GET("/runs/:id/wait", h.WaitChaos)
+	v1.GET("/runs/:id/timeline", h.GetTimeline)
+	v1.GET("/runs/:id/events", h.StreamEvents)
+	v1.GET("/runs/:id/logs", h.ListLogs)
+	v1.GET("/runs/:id/logs/archive", h.DownloadLogs)
+	v1.GET("/runs/:id/logs/stream", h.StreamLogs)
+	v1.GET("/runs/:id/evidence", h.DownloadEvidence)
+	v1.GET("/runs/:id/report", h.GetReport)
+
+	v1.POST("/webhooks", h.CreateWebhook)
+	v1.GET("/webhooks", h.ListWebhooks)
+	v1.DELETE("/webhooks/:id", h.DeleteWebhook)
+	v1.GET("/webhooks/:id/deliveries", h.ListWebhookDeliveries)
+
+	v1.POST("/notifications", h.CreateNotification)
+	v1.GET("/notifications", h.ListNotifications)
+	v1.DELETE("/notifications/:id", h.DeleteNotification)
+
+	// the routes before /api/v1, kept as deprecated aliases
 	chaosGrp := router.Group("/chaos")
 
-	chaosGrp.POST("/create", chaos.CreateChaos)
-	chaosGrp.POST("/create/one", chaos.CreateChaosOne)
-	chaosGrp.GET("/get", chaos.GetChaos)
+	chaosGrp.POST("/create", Deprecated("/api/v1/scenarios/{name}/runs"), h.CreateChaos)
+	chaosGrp.POST("/create/one", Deprecated("/api/v1/runs"), h.CreateChaosOne)
+	chaosGrp.GET("/get", Deprecated("/api/v1/scenarios/{name}"), h.GetChaos)
+	chaosGrp.PUT("/scenario", Deprecated("/api/v1/scenarios/{name}"), h.ApplyScenario)
+	chaosGrp.GET("/scenarios", Deprecated("/api/v1/scenarios"), h.ListScenarios)
+	chaosGrp.DELETE("/scenario", Deprecated("/api/v1/scenarios/{name}"), h.DeleteScenario)
+	chaosGrp.GET("/run", Deprecated("/api/v1/runs/{id}"), h.GetRun)
+	chaosGrp.GET("/timeline", Deprecated("/api/v1/runs/{id}/timeline"), h.GetTimeline)
+	chaosGrp.DELETE("/run", Deprecated("/api/v1/runs/{id}"), h.PurgeRun)
+	chaosGrp.POST("/abort", Deprecated("/api/v1/runs/{id}/abort"), h.AbortChaos)
+	chaosGrp.GET("/logs", Deprecated("/api/v1/runs/{id}/logs"), h.ListLogs)
+	chaosGrp.GET("/logs/download", Deprecated("/api/v1/runs/{id}/logs/archive"), h.DownloadLogs)
+	chaosGrp.GET("/events/stream", Deprecated("/api/v1/runs/{id}/events"), h.StreamEvents)
+	chaosGrp.GET("/logs/stream", Deprecated("/api/v1/runs/{id}/logs/stream"), h.StreamLogs)
+	chaosGrp.GET("/evidence", Deprecated("/api/v1/runs/{id}/evidence"), h.DownloadEvidence)
+	chaosGrp.GET("/report", Deprecated("/api/v1/runs/{id}/report"), h.GetReport)
+	chaosGrp.GET("/wait", Deprecated("/api/v1/runs/{id}/wait"), h.WaitChaos)
+
+	webhookGrp := router.Group("/webhooks")
+
+	webhookGrp.POST("", Deprecated("/api/v1/webhooks"), h.CreateWebhook)
+	webhookGrp.GET("", Deprecated("/api/v1/webhooks"), h.ListWebhooks)
+	webhookGrp.DELETE("", Deprecated("/api/v1/webhooks/{id}"), h.DeleteWebhook)
+	webhookGrp.GET("/deliveries", Deprecated("/api/v1/webhooks/{id}/deliveries"), h.ListWebhookDeliveries)
+
+	notificationGrp := router.Group("/notifications")
+
+	notificationGrp.POST("", Deprecated("/api/v1/notifications"), h.CreateNotification)
+	notificationGrp.GET("", Deprecated("/api/v1/notifications"), h.ListNotifications)
+	notificationGrp.DELETE("", Deprecated("/api/v1/notifications/{id}"), h.DeleteNotification)
+
 	return router
 }
@@ -0,0 +1,83 @@
+/*
+ *
+ *  * Licensed to the Apache Software Foundation (ASF) under one
+ *  * or more contributor license agreements.  See the NOTICE file
+ *  * distributed with this work for additional information
+ *  * regarding copyright ownership.  The ASF licenses this file
+ *  * to you under the Apache License, Version 2.0 (the
+ *  * "License"); you may not use this file except in compliance
+ *  * with the License.  You may obtain a copy of the License at
+ *  *
+ *  *     http://www.apache.org/licenses/LICENSE-2.0
+ *  *
+ *  * Unless required by applicable law or agreed to in writing, software
+ *  * distributed under the License is distributed on an "AS IS" BASIS,
+ *  * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  * See the License for the specific language governing permissions and
+ *  * limitations under the License.
+ *
+ *
+ */
+package core
+
+import (
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gin-gonic/gin"
+	"godzilla/chaos"
+	"godzilla/db"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// openApiPath turns the gin parameters of the path into the openapi ones, /runs/:id into /runs/{id}.
+func openApiPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// TestOpenApi validates the document and reports the /api/v1 routes missing in it and the operations
+// of it without a route.
+func TestOpenApi(t *testing.T) {
+	loader := openapi3.NewLoader()
+	document, err := loader.LoadFromData(openApiDocument)
+	if err != nil {
+		t.Fatalf("load openapi document failed, reason: %s", err.Error())
+	}
+	err = document.Validate(loader.Context)
+	if err != nil {
+		t.Fatalf("openapi document is not valid, reason: %s", err.Error())
+	}
+
+	documented := make(map[string]bool)
+	for path, item := range document.Paths.Map() {
+		for method := range item.Operations() {
+			documented[method+" "+path] = true
+		}
+	}
+	gin.SetMode(gin.TestMode)
+	router := SetupRouter(chaos.NewHandler(db.NewMemoryStore(), nil, nil))
+	var problems []string
+	for _, route := range router.Routes() {
+		if !strings.HasPrefix(route.Path, apiV1+"/") {
+			continue
+		}
+		operation := route.Method + " " + openApiPath(strings.TrimPrefix(route.Path, apiV1))
+		if !documented[operation] {
+			problems = append(problems, operation+" is not documented")
+		}
+		delete(documented, operation)
+	}
+	for operation := range documented {
+		problems = append(problems, operation+" has no route")
+	}
+	sort.Strings(problems)
+	for _, problem := range problems {
+		t.Error(problem)
+	}
+}
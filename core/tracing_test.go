@@ -0,0 +1,75 @@
+/*
+ *
+ *  * Licensed to the Apache Software Foundation (ASF) under one
+ *  * or more contributor license agreements.  See the NOTICE file
+ *  * distributed with this work for additional information
+ *  * regarding copyright ownership.  The ASF licenses this file
+ *  * to you under the Apache License, Version 2.0 (the
+ *  * "License"); you may not use this file except in compliance
+ *  * with the License.  You may obtain a copy of the License at
+ *  *
+ *  *     http://www.apache.org/licenses/LICENSE-2.0
+ *  *
+ *  * Unless required by applicable law or agreed to in writing, software
+ *  * distributed under the License is distributed on an "AS IS" BASIS,
+ *  * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  * See the License for the specific language governing permissions and
+ *  * limitations under the License.
+ *
+ *
+ */
+
+package core
+
+import (
+	"context"
+	"go.opentelemetry.io/otel"
+	sdkTrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"godzilla/env"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestInitTracing(t *testing.T) {
+	// the collector stand-in takes the OTLP/HTTP exports
+	exports := make(chan []byte, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/traces" {
+			t.Errorf("collector got %s %s, want POST /v1/traces", r.Method, r.URL.Path)
+		}
+		body, _ := io.ReadAll(r.Body)
+		exports <- body
+	}))
+	defer collector.Close()
+
+	defer func(endpoint string, provider trace.TracerProvider) {
+		env.OtlpEndpoint = endpoint
+		otel.SetTracerProvider(provider)
+	}(env.OtlpEndpoint, otel.GetTracerProvider())
+	env.OtlpEndpoint = collector.URL
+	InitTracing()
+
+	provider, ok := otel.GetTracerProvider().(*sdkTrace.TracerProvider)
+	if !ok {
+		t.Fatalf("tracer provider is %T, want the sdk one", otel.GetTracerProvider())
+	}
+	defer provider.Shutdown(context.Background())
+	_, span := otel.Tracer("test").Start(context.Background(), "chaos.run")
+	span.End()
+	err := provider.ForceFlush(context.Background())
+	if err != nil {
+		t.Fatalf("flush spans failed, reason: %s", err.Error())
+	}
+
+	select {
+	case body := <-exports:
+		if len(body) == 0 {
+			t.Error("collector got an empty export")
+		}
+	default:
+		t.Error("collector got no export")
+	}
+}
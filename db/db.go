@@ -0,0 +1,118 @@
+/*
+ *
+ *  * Licensed to the Apache Software Foundation (ASF) under one
+ *  * or more contributor license agreements.  See the NOTICE file
+ *  * distributed with this work for additional information
+ *  * regarding copyright ownership.  The ASF licenses this file
+ *  * to you under the Apache License, Version 2.0 (the
+ *  * "License"); you may not use this file except in compliance
+ *  * with the License.  You may obtain a copy of the License at
+ *  *
+ *  *     http://www.apache.org/licenses/LICENSE-2.0
+ *  *
+ *  * Unless required by applicable law or agreed to in writing, software
+ *  * distributed under the License is distributed on an "AS IS" BASIS,
+ *  * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  * See the License for the specific language governing permissions and
+ *  * limitations under the License.
+ *
+ *
+ */
+
+package db
+
+import (
+	"fmt"
+	"github.com/glebarez/sqlite"
+	"github.com/sirupsen/logrus"
+	"godzilla/env"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+const (
+	MysqlDriver    = "mysql"
+	PostgresDriver = "postgres"
+	SqliteDriver   = "sqlite"
+	MemoryDriver   = "memory"
+)
+
+// Open connects to the configured database and returns the stores on top of it.
+func Open() Store {
+	store, err := OpenDriver(env.DbDriver, env.DbDsn)
+	if err != nil {
+		logrus.Fatalf("open %s database failed, reason: %s", env.DbDriver, err.Error())
+	}
+	return store
+}
+
+// OpenDriver returns the stores on top of the database of the driver.
+// The memory driver keeps everything in the process, its dsn is an optional directory of scenario yaml files.
+func OpenDriver(driver, dsn string) (Store, error) {
+	if driver == MemoryDriver {
+		store := NewMemoryStore()
+		if dsn != "" {
+			err := loadScenarios(store.Scenarios, dsn)
+			if err != nil {
+				return Store{}, fmt.Errorf("load scenarios from %s failed, reason: %s", dsn, err.Error())
+			}
+		}
+		return store, nil
+	}
+
+	dialector, err := dialector(driver, dsn)
+	if err != nil {
+		return Store{}, err
+	}
+	// mysql schema is managed by prepare.sql, the others are created on start
+	return openGorm(dialector, driver != MysqlDriver)
+}
+
+// openGorm returns the stores on top of the database, migrate creates the missing tables and columns.
+func openGorm(dialector gorm.Dialector, migrate bool) (Store, error) {
+	conn, err := gorm.Open(dialector, &gorm.Config{
+		SkipDefaultTransaction: true,
+		PrepareStmt:            true,
+	})
+	if err != nil {
+		return Store{}, err
+	}
+	if migrate {
+		err = conn.AutoMigrate(&Scenario{}, &JobStatus{}, &JobEvent{}, &Webhook{}, &WebhookDelivery{},
+			&ChatNotification{})
+		if err != nil {
+			return Store{}, err
+		}
+	}
+	return Store{
+		Scenarios:     &gormScenarioStore{db: conn},
+		Runs:          &gormRunStore{db: conn},
+		Events:        &gormEventStore{db: conn},
+		Webhooks:      &gormWebhookStore{db: conn},
+		Notifications: &gormNotificationStore{db: conn},
+	}, nil
+}
+
+func dialector(driver, dsn string) (gorm.Dialector, error) {
+	switch driver {
+	case MysqlDriver:
+		if dsn == "" {
+			dsn = fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=%s", env.MysqlUser, env.MysqlPassword, env.MysqlHost,
+				env.MysqlPort, env.MysqlDatabase, "utf8mb4&parseTime=True")
+		}
+		return mysql.Open(dsn), nil
+	case PostgresDriver:
+		if dsn == "" {
+			return nil, fmt.Errorf("GODZILLA_DB_DSN is required for driver %s", driver)
+		}
+		return postgres.Open(dsn), nil
+	case SqliteDriver:
+		if dsn == "" {
+			dsn = "godzilla.db?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+		}
+		return sqlite.Open(dsn), nil
+	default:
+		return nil, fmt.Errorf("unsupported database driver %s", driver)
+	}
+}
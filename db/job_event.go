@@ -0,0 +1,65 @@
+/*
+ *
+ *  * Licensed to the Apache Software Foundation (ASF) under one
+ *  * or more contributor license agreements.  See the NOTICE file
+ *  * distributed with this work for additional information
+ *  * regarding copyright ownership.  The ASF licenses this file
+ *  * to you under the Apache License, Version 2.0 (the
+ *  * "License"); you may not use this file except in compliance
+ *  * with the License.  You may obtain a copy of the License at
+ *  *
+ *  *     http://www.apache.org/licenses/LICENSE-2.0
+ *  *
+ *  * Unless required by applicable law or agreed to in writing, software
+ *  * distributed under the License is distributed on an "AS IS" BASIS,
+ *  * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  * See the License for the specific language governing permissions and
+ *  * limitations under the License.
+ *
+ *
+ */
+
+package db
+
+import (
+	"godzilla/types"
+	"gorm.io/gorm"
+	"time"
+)
+
+// JobEvent is one status transition of a step, events are only appended and never updated.
+type JobEvent struct {
+	Id         uint      `gorm:"primarykey" json:"id"`
+	CreatedAt  time.Time `json:"createdAt"`
+	RunId      uint      `gorm:"not null;index" json:"runId"`
+	Step       string    `gorm:"size:255;not null" json:"step"`
+	FromStatus string    `gorm:"size:32;not null" json:"fromStatus"`
+	ToStatus   string    `gorm:"size:32;not null" json:"toStatus"`
+	Reason     string    `json:"reason,omitempty"`
+	TargetPods []string  `gorm:"serializer:json" json:"targetPods,omitempty"`
+	JobNames   []string  `gorm:"serializer:json" json:"jobNames,omitempty"`
+}
+
+// the events are answered as they are stored, the conversion breaks the build if the wire type drifts
+var _ = types.JobEvent(JobEvent{})
+
+func (*JobEvent) TableName() string {
+	return "job_event"
+}
+
+type gormEventStore struct {
+	db *gorm.DB
+}
+
+func (s *gormEventStore) Add(event *JobEvent) error {
+	return s.db.Create(event).Error
+}
+
+func (s *gormEventStore) ListByRunId(runId uint) (events []JobEvent, err error) {
+	err = s.db.Where("run_id = ?", runId).Order("created_at, id").Find(&events).Error
+	return events, err
+}
+
+func (s *gormEventStore) DeleteByRunId(runId uint) error {
+	return s.db.Where("run_id = ?", runId).Delete(&JobEvent{}).Error
+}
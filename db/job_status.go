@@ -21,29 +21,75 @@
 
 package db
 
+import (
+	"errors"
+	"gorm.io/gorm"
+	"math"
+	"time"
+)
+
+var ErrVersionConflict = errors.New("job status was updated concurrently")
+
 type JobStatus struct {
 	Base
 	ScenarioId uint
-	Status     string
+	Status     string `gorm:"not null"`
+	Version    uint   `gorm:"not null;default:0"`
+	TraceId    string `gorm:"size:32"`
+	// TriggeredBy is who started the run
+	TriggeredBy string `gorm:"size:255"`
 }
 
 func (*JobStatus) TableName() string {
 	return "job_status"
 }
 
-func (j *JobStatus) Add() error {
-	return Db.Create(&j).Error
+type gormRunStore struct {
+	db *gorm.DB
+}
+
+func (s *gormRunStore) Add(run *JobStatus) error {
+	return s.db.Create(run).Error
+}
+
+func (s *gormRunStore) GetById(id uint) (run JobStatus, err error) {
+	err = s.db.Where("id = ?", id).Find(&run).Error
+	return run, err
+}
+
+func (s *gormRunStore) UpdateById(run *JobStatus) error {
+	result := s.db.Model(&JobStatus{}).Where("id = ? AND version = ?", run.Id, run.Version).Updates(map[string]any{
+		"updated_at": run.UpdatedAt,
+		"status":     run.Status,
+		"version":    run.Version + 1,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrVersionConflict
+	}
+	run.Version++
+	return nil
+}
+
+func (s *gormRunStore) DeleteById(id uint) error {
+	return s.db.Delete(&JobStatus{}, id).Error
+}
+
+func (s *gormRunStore) ListBefore(t time.Time) (runs []JobStatus, err error) {
+	err = s.db.Where("created_at < ?", t).Order("id").Find(&runs).Error
+	return runs, err
 }
 
-func (j *JobStatus) GetById() error {
-	return Db.Where("id = ?", j.Id).Find(&j).Error
+func (s *gormRunStore) ListByScenarioId(scenarioId uint, offset int) (runs []JobStatus, err error) {
+	// mysql and sqlite only take an offset after a limit
+	err = s.db.Where("scenario_id = ?", scenarioId).Order("id desc").Limit(math.MaxInt32).Offset(offset).
+		Find(&runs).Error
+	return runs, err
 }
 
-func (j *JobStatus) UpdateById() error {
-	return Db.Model(&j).Updates(JobStatus{
-		Base: Base{
-			UpdatedAt: j.UpdatedAt,
-		},
-		Status: j.Status,
-	}).Error
+func (s *gormRunStore) ScenarioIds() (ids []uint, err error) {
+	err = s.db.Model(&JobStatus{}).Where("scenario_id > 0").Distinct().Pluck("scenario_id", &ids).Error
+	return ids, err
 }
@@ -0,0 +1,402 @@
+/*
+ *
+ *  * Licensed to the Apache Software Foundation (ASF) under one
+ *  * or more contributor license agreements.  See the NOTICE file
+ *  * distributed with this work for additional information
+ *  * regarding copyright ownership.  The ASF licenses this file
+ *  * to you under the Apache License, Version 2.0 (the
+ *  * "License"); you may not use this file except in compliance
+ *  * with the License.  You may obtain a copy of the License at
+ *  *
+ *  *     http://www.apache.org/licenses/LICENSE-2.0
+ *  *
+ *  * Unless required by applicable law or agreed to in writing, software
+ *  * distributed under the License is distributed on an "AS IS" BASIS,
+ *  * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  * See the License for the specific language governing permissions and
+ *  * limitations under the License.
+ *
+ *
+ */
+
+package db
+
+import (
+	"errors"
+	"github.com/sirupsen/logrus"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memoryScenarioStore and memoryRunStore keep everything in the process, they follow the gorm stores
+// and return an empty record without error when nothing is found.
+type memoryScenarioStore struct {
+	mu        sync.RWMutex
+	lastId    uint
+	scenarios map[string]Scenario
+}
+
+func (s *memoryScenarioStore) Add(scenario *Scenario) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.scenarios[scenario.Name]; ok {
+		return errors.New("duplicate scenario name " + scenario.Name)
+	}
+	s.lastId++
+	scenario.Id = s.lastId
+	scenario.CreatedAt = time.Now()
+	scenario.UpdatedAt = scenario.CreatedAt
+	s.scenarios[scenario.Name] = *scenario
+	return nil
+}
+
+func (s *memoryScenarioStore) GetByName(name string) (Scenario, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.scenarios[name], nil
+}
+
+func (s *memoryScenarioStore) List() ([]Scenario, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	scenarios := make([]Scenario, 0, len(s.scenarios))
+	for _, scenario := range s.scenarios {
+		scenarios = append(scenarios, scenario)
+	}
+	sort.Slice(scenarios, func(i, j int) bool {
+		return scenarios[i].Name < scenarios[j].Name
+	})
+	return scenarios, nil
+}
+
+func (s *memoryScenarioStore) Update(scenario *Scenario) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name, stored := range s.scenarios {
+		if stored.Id == scenario.Id {
+			stored.Definition = scenario.Definition
+			stored.UpdatedAt = scenario.UpdatedAt
+			s.scenarios[name] = stored
+			return nil
+		}
+	}
+	return nil
+}
+
+func (s *memoryScenarioStore) DeleteByName(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.scenarios, name)
+	return nil
+}
+
+func (s *memoryScenarioStore) GetById(id uint) (Scenario, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, scenario := range s.scenarios {
+		if scenario.Id == id {
+			return scenario, nil
+		}
+	}
+	return Scenario{}, nil
+}
+
+type memoryRunStore struct {
+	mu     sync.RWMutex
+	lastId uint
+	runs   map[uint]JobStatus
+}
+
+func (s *memoryRunStore) Add(run *JobStatus) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastId++
+	run.Id = s.lastId
+	run.CreatedAt = time.Now()
+	run.UpdatedAt = run.CreatedAt
+	s.runs[run.Id] = *run
+	return nil
+}
+
+func (s *memoryRunStore) GetById(id uint) (JobStatus, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.runs[id], nil
+}
+
+func (s *memoryRunStore) UpdateById(run *JobStatus) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.runs[run.Id]
+	if !ok || stored.Version != run.Version {
+		return ErrVersionConflict
+	}
+	run.Version++
+	stored.UpdatedAt = run.UpdatedAt
+	stored.Status = run.Status
+	stored.Version = run.Version
+	s.runs[run.Id] = stored
+	return nil
+}
+
+func (s *memoryRunStore) DeleteById(id uint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.runs, id)
+	return nil
+}
+
+func (s *memoryRunStore) ListBefore(t time.Time) ([]JobStatus, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var runs []JobStatus
+	for _, run := range s.runs {
+		if run.CreatedAt.Before(t) {
+			runs = append(runs, run)
+		}
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].Id < runs[j].Id
+	})
+	return runs, nil
+}
+
+func (s *memoryRunStore) ListByScenarioId(scenarioId uint, offset int) ([]JobStatus, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var runs []JobStatus
+	for _, run := range s.runs {
+		if run.ScenarioId == scenarioId {
+			runs = append(runs, run)
+		}
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].Id > runs[j].Id
+	})
+	if offset >= len(runs) {
+		return nil, nil
+	}
+	return runs[offset:], nil
+}
+
+func (s *memoryRunStore) ScenarioIds() ([]uint, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	seen := make(map[uint]bool)
+	var ids []uint
+	for _, run := range s.runs {
+		if run.ScenarioId != 0 && !seen[run.ScenarioId] {
+			seen[run.ScenarioId] = true
+			ids = append(ids, run.ScenarioId)
+		}
+	}
+	return ids, nil
+}
+
+type memoryEventStore struct {
+	mu     sync.RWMutex
+	lastId uint
+	events []JobEvent
+}
+
+func (s *memoryEventStore) Add(event *JobEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastId++
+	event.Id = s.lastId
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+	s.events = append(s.events, *event)
+	return nil
+}
+
+func (s *memoryEventStore) ListByRunId(runId uint) ([]JobEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var events []JobEvent
+	for _, e := range s.events {
+		if e.RunId == runId {
+			events = append(events, e)
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].CreatedAt.Before(events[j].CreatedAt)
+	})
+	return events, nil
+}
+
+func (s *memoryEventStore) DeleteByRunId(runId uint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	events := s.events[:0]
+	for _, e := range s.events {
+		if e.RunId != runId {
+			events = append(events, e)
+		}
+	}
+	s.events = events
+	return nil
+}
+
+type memoryWebhookStore struct {
+	mu             sync.RWMutex
+	lastId         uint
+	lastDeliveryId uint
+	webhooks       []Webhook
+	deliveries     []WebhookDelivery
+}
+
+func (s *memoryWebhookStore) Add(webhook *Webhook) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastId++
+	webhook.Id = s.lastId
+	webhook.CreatedAt = time.Now()
+	webhook.UpdatedAt = webhook.CreatedAt
+	s.webhooks = append(s.webhooks, *webhook)
+	return nil
+}
+
+func (s *memoryWebhookStore) List() ([]Webhook, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]Webhook(nil), s.webhooks...), nil
+}
+
+func (s *memoryWebhookStore) DeleteById(id uint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	webhooks := s.webhooks[:0]
+	for _, w := range s.webhooks {
+		if w.Id != id {
+			webhooks = append(webhooks, w)
+		}
+	}
+	s.webhooks = webhooks
+	deliveries := s.deliveries[:0]
+	for _, d := range s.deliveries {
+		if d.WebhookId != id {
+			deliveries = append(deliveries, d)
+		}
+	}
+	s.deliveries = deliveries
+	return nil
+}
+
+func (s *memoryWebhookStore) AddDelivery(delivery *WebhookDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastDeliveryId++
+	delivery.Id = s.lastDeliveryId
+	delivery.CreatedAt = time.Now()
+	delivery.UpdatedAt = delivery.CreatedAt
+	s.deliveries = append(s.deliveries, *delivery)
+	return nil
+}
+
+func (s *memoryWebhookStore) UpdateDelivery(delivery *WebhookDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.deliveries {
+		if s.deliveries[i].Id == delivery.Id {
+			s.deliveries[i] = *delivery
+		}
+	}
+	return nil
+}
+
+func (s *memoryWebhookStore) ListDeliveries(webhookId uint) ([]WebhookDelivery, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var deliveries []WebhookDelivery
+	for i := len(s.deliveries) - 1; i >= 0; i-- {
+		if s.deliveries[i].WebhookId == webhookId {
+			deliveries = append(deliveries, s.deliveries[i])
+		}
+	}
+	return deliveries, nil
+}
+
+type memoryNotificationStore struct {
+	mu            sync.RWMutex
+	lastId        uint
+	notifications []ChatNotification
+}
+
+func (s *memoryNotificationStore) Add(notification *ChatNotification) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastId++
+	notification.Id = s.lastId
+	notification.CreatedAt = time.Now()
+	notification.UpdatedAt = notification.CreatedAt
+	s.notifications = append(s.notifications, *notification)
+	return nil
+}
+
+func (s *memoryNotificationStore) List() ([]ChatNotification, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]ChatNotification(nil), s.notifications...), nil
+}
+
+func (s *memoryNotificationStore) ListByScenario(scenario string) ([]ChatNotification, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var notifications []ChatNotification
+	for _, n := range s.notifications {
+		if n.Scenario == scenario {
+			notifications = append(notifications, n)
+		}
+	}
+	return notifications, nil
+}
+
+func (s *memoryNotificationStore) DeleteById(id uint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	notifications := s.notifications[:0]
+	for _, n := range s.notifications {
+		if n.Id != id {
+			notifications = append(notifications, n)
+		}
+	}
+	s.notifications = notifications
+	return nil
+}
+
+func NewMemoryStore() Store {
+	return Store{
+		Scenarios:     &memoryScenarioStore{scenarios: make(map[string]Scenario)},
+		Runs:          &memoryRunStore{runs: make(map[uint]JobStatus)},
+		Events:        &memoryEventStore{},
+		Webhooks:      &memoryWebhookStore{},
+		Notifications: &memoryNotificationStore{},
+	}
+}
+
+// loadScenarios adds every yaml file inside dir as a scenario named after the file.
+func loadScenarios(store ScenarioStore, dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.y*ml"))
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return err
+		}
+		name := strings.TrimSuffix(filepath.Base(f), filepath.Ext(f))
+		err = store.Add(&Scenario{Name: name, Definition: string(data)})
+		if err != nil {
+			return err
+		}
+		logrus.Infof("scenario %s loaded from %s", name, f)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+/*
+ *
+ *  * Licensed to the Apache Software Foundation (ASF) under one
+ *  * or more contributor license agreements.  See the NOTICE file
+ *  * distributed with this work for additional information
+ *  * regarding copyright ownership.  The ASF licenses this file
+ *  * to you under the Apache License, Version 2.0 (the
+ *  * "License"); you may not use this file except in compliance
+ *  * with the License.  You may obtain a copy of the License at
+ *  *
+ *  *     http://www.apache.org/licenses/LICENSE-2.0
+ *  *
+ *  * Unless required by applicable law or agreed to in writing, software
+ *  * distributed under the License is distributed on an "AS IS" BASIS,
+ *  * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  * See the License for the specific language governing permissions and
+ *  * limitations under the License.
+ *
+ *
+ */
+
+package db
+
+import (
+	"encoding/json"
+	"gorm.io/gorm"
+	"net/url"
+	"time"
+)
+
+// ChatNotification posts the events of a scenario to a Slack compatible incoming webhook,
+// an empty Template uses the default message. The Url is a credential of the chat, only its host is answered.
+type ChatNotification struct {
+	Id        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	Scenario  string    `gorm:"size:255;not null;index" json:"scenario"`
+	Url       string    `gorm:"size:2048;not null" json:"-"`
+	Events    []string  `gorm:"serializer:json;not null" json:"events"`
+	Template  string    `json:"template,omitempty"`
+}
+
+func (*ChatNotification) TableName() string {
+	return "chat_notification"
+}
+
+// chatNotificationJson has the fields of ChatNotification without its MarshalJSON.
+type chatNotificationJson ChatNotification
+
+// MarshalJSON answers the host of Url in place of the url.
+func (n ChatNotification) MarshalJSON() ([]byte, error) {
+	var host string
+	if u, err := url.Parse(n.Url); err == nil {
+		host = u.Host
+	}
+	return json.Marshal(struct {
+		chatNotificationJson
+		Host string `json:"host"`
+	}{chatNotificationJson(n), host})
+}
+
+type gormNotificationStore struct {
+	db *gorm.DB
+}
+
+func (s *gormNotificationStore) Add(notification *ChatNotification) error {
+	return s.db.Create(notification).Error
+}
+
+func (s *gormNotificationStore) List() (notifications []ChatNotification, err error) {
+	err = s.db.Order("id").Find(&notifications).Error
+	return notifications, err
+}
+
+func (s *gormNotificationStore) ListByScenario(scenario string) (notifications []ChatNotification, err error) {
+	err = s.db.Where("scenario = ?", scenario).Order("id").Find(&notifications).Error
+	return notifications, err
+}
+
+func (s *gormNotificationStore) DeleteById(id uint) error {
+	return s.db.Delete(&ChatNotification{}, id).Error
+}
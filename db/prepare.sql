@@ -20,5 +20,76 @@ create table godzilla.job_status
     status      longtext                            not null,
     created_at  timestamp default CURRENT_TIMESTAMP null,
     updated_at  timestamp default CURRENT_TIMESTAMP not null,
-    reason      text null
+    reason      text null,
+    version     int       default 0                 not null,
+    trace_id    varchar(32) null,
+    triggered_by varchar(255) null
 );
+
+-- upgrade an existing database
+-- alter table godzilla.job_status add version int default 0 not null;
+-- alter table godzilla.job_status add trace_id varchar(32) null;
+-- alter table godzilla.job_status add triggered_by varchar(255) null;
+
+create table godzilla.job_event
+(
+    id          int auto_increment
+        primary key,
+    run_id      int                                 not null,
+    step        varchar(255)                        not null,
+    from_status varchar(32)                         not null,
+    to_status   varchar(32)                         not null,
+    reason      text null,
+    target_pods text null,
+    job_names   text null,
+    created_at  timestamp(3) default CURRENT_TIMESTAMP(3) null
+);
+
+create index job_event_run_id_index
+    on godzilla.job_event (run_id);
+
+create table godzilla.webhook
+(
+    id         int auto_increment
+        primary key,
+    url        varchar(2048)                       not null,
+    events     text                                not null,
+    scenario   varchar(255) null,
+    secret     varchar(255) null,
+    created_at timestamp default CURRENT_TIMESTAMP null,
+    updated_at timestamp default CURRENT_TIMESTAMP not null
+);
+
+create table godzilla.webhook_delivery
+(
+    id          int auto_increment
+        primary key,
+    webhook_id  int                                 not null,
+    event       varchar(64)                         not null,
+    run_id      int null,
+    payload     longtext                            not null,
+    attempts    int       default 0                 not null,
+    status_code int null,
+    error       text null,
+    delivered   tinyint(1) default 0                not null,
+    created_at  timestamp default CURRENT_TIMESTAMP null,
+    updated_at  timestamp default CURRENT_TIMESTAMP not null
+);
+
+create index webhook_delivery_webhook_id_index
+    on godzilla.webhook_delivery (webhook_id);
+
+create table godzilla.chat_notification
+(
+    id         int auto_increment
+        primary key,
+    scenario   varchar(255)                        not null,
+    url        varchar(2048)                       not null,
+    events     text                                not null,
+    template   text null,
+    created_at timestamp default CURRENT_TIMESTAMP null,
+    updated_at timestamp default CURRENT_TIMESTAMP not null
+);
+
+create index chat_notification_scenario_index
+    on godzilla.chat_notification (scenario);
@@ -21,16 +21,48 @@
 
 package db
 
+import "gorm.io/gorm"
+
 type Scenario struct {
 	Base
-	Name       string
-	Definition string
+	Name       string `gorm:"size:255;not null;uniqueIndex:scenario_pk" json:"name"`
+	Definition string `gorm:"not null" json:"definition"`
 }
 
 func (*Scenario) TableName() string {
 	return "scenario"
 }
 
-func (s *Scenario) GetByName() error {
-	return Db.Where("name = ?", s.Name).Find(&s).Error
+type gormScenarioStore struct {
+	db *gorm.DB
+}
+
+func (s *gormScenarioStore) Add(scenario *Scenario) error {
+	return s.db.Create(scenario).Error
+}
+
+func (s *gormScenarioStore) GetByName(name string) (scenario Scenario, err error) {
+	err = s.db.Where("name = ?", name).Find(&scenario).Error
+	return scenario, err
+}
+
+func (s *gormScenarioStore) GetById(id uint) (scenario Scenario, err error) {
+	err = s.db.Where("id = ?", id).Find(&scenario).Error
+	return scenario, err
+}
+
+func (s *gormScenarioStore) List() (scenarios []Scenario, err error) {
+	err = s.db.Order("name").Find(&scenarios).Error
+	return scenarios, err
+}
+
+func (s *gormScenarioStore) Update(scenario *Scenario) error {
+	return s.db.Model(scenario).Updates(map[string]any{
+		"definition": scenario.Definition,
+		"updated_at": scenario.UpdatedAt,
+	}).Error
+}
+
+func (s *gormScenarioStore) DeleteByName(name string) error {
+	return s.db.Where("name = ?", name).Delete(&Scenario{}).Error
 }
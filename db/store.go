@@ -0,0 +1,85 @@
+/*
+ *
+ *  * Licensed to the Apache Software Foundation (ASF) under one
+ *  * or more contributor license agreements.  See the NOTICE file
+ *  * distributed with this work for additional information
+ *  * regarding copyright ownership.  The ASF licenses this file
+ *  * to you under the Apache License, Version 2.0 (the
+ *  * "License"); you may not use this file except in compliance
+ *  * with the License.  You may obtain a copy of the License at
+ *  *
+ *  *     http://www.apache.org/licenses/LICENSE-2.0
+ *  *
+ *  * Unless required by applicable law or agreed to in writing, software
+ *  * distributed under the License is distributed on an "AS IS" BASIS,
+ *  * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  * See the License for the specific language governing permissions and
+ *  * limitations under the License.
+ *
+ *
+ */
+
+package db
+
+import "time"
+
+// ScenarioStore keeps the scenario definitions.
+type ScenarioStore interface {
+	Add(scenario *Scenario) error
+	GetByName(name string) (Scenario, error)
+	GetById(id uint) (Scenario, error)
+	List() ([]Scenario, error)
+	// Update replaces the definition of the scenario with the same id
+	Update(scenario *Scenario) error
+	DeleteByName(name string) error
+}
+
+// RunStore keeps the status of every run, the status is the yaml of the running scenario.
+// UpdateById only succeeds when the stored version still equals run.Version, otherwise it
+// returns ErrVersionConflict and the caller has to read the run again.
+type RunStore interface {
+	Add(run *JobStatus) error
+	GetById(id uint) (JobStatus, error)
+	UpdateById(run *JobStatus) error
+	DeleteById(id uint) error
+	// ListBefore returns the runs created before t.
+	ListBefore(t time.Time) ([]JobStatus, error)
+	// ListByScenarioId returns the runs of a scenario from the newest one, skipping the first offset runs.
+	ListByScenarioId(scenarioId uint, offset int) ([]JobStatus, error)
+	// ScenarioIds returns the scenarios having runs, runs created without a stored scenario are left out.
+	ScenarioIds() ([]uint, error)
+}
+
+// EventStore keeps the timeline of every run, ListByRunId returns the events in order.
+type EventStore interface {
+	Add(event *JobEvent) error
+	ListByRunId(runId uint) ([]JobEvent, error)
+	DeleteByRunId(runId uint) error
+}
+
+// WebhookStore keeps the registered webhooks and the history of their deliveries,
+// ListDeliveries returns the newest delivery first.
+type WebhookStore interface {
+	Add(webhook *Webhook) error
+	List() ([]Webhook, error)
+	DeleteById(id uint) error
+	AddDelivery(delivery *WebhookDelivery) error
+	UpdateDelivery(delivery *WebhookDelivery) error
+	ListDeliveries(webhookId uint) ([]WebhookDelivery, error)
+}
+
+// NotificationStore keeps the chat notifications configured for the scenarios.
+type NotificationStore interface {
+	Add(notification *ChatNotification) error
+	List() ([]ChatNotification, error)
+	ListByScenario(scenario string) ([]ChatNotification, error)
+	DeleteById(id uint) error
+}
+
+type Store struct {
+	Scenarios     ScenarioStore
+	Runs          RunStore
+	Events        EventStore
+	Webhooks      WebhookStore
+	Notifications NotificationStore
+}
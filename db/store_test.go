@@ -0,0 +1,316 @@
+/*
+ *
+ *  * Licensed to the Apache Software Foundation (ASF) under one
+ *  * or more contributor license agreements.  See the NOTICE file
+ *  * distributed with this work for additional information
+ *  * regarding copyright ownership.  The ASF licenses this file
+ *  * to you under the Apache License, Version 2.0 (the
+ *  * "License"); you may not use this file except in compliance
+ *  * with the License.  You may obtain a copy of the License at
+ *  *
+ *  *     http://www.apache.org/licenses/LICENSE-2.0
+ *  *
+ *  * Unless required by applicable law or agreed to in writing, software
+ *  * distributed under the License is distributed on an "AS IS" BASIS,
+ *  * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  * See the License for the specific language governing permissions and
+ *  * limitations under the License.
+ *
+ *
+ */
+
+package db
+
+import (
+	"errors"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// forEachStore runs the test against every implementation of the stores, they have to behave the same.
+func forEachStore(t *testing.T, test func(t *testing.T, store Store)) {
+	t.Run(MemoryDriver, func(t *testing.T) {
+		test(t, NewMemoryStore())
+	})
+	t.Run(SqliteDriver, func(t *testing.T) {
+		store, err := OpenDriver(SqliteDriver, filepath.Join(t.TempDir(), "godzilla.db"))
+		if err != nil {
+			t.Fatalf("open sqlite failed, reason: %s", err.Error())
+		}
+		test(t, store)
+	})
+}
+
+func TestScenarioStore(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		for _, name := range []string{"b", "a"} {
+			err := store.Scenarios.Add(&Scenario{Name: name, Definition: "definition of " + name})
+			if err != nil {
+				t.Fatalf("add scenario %s failed, reason: %s", name, err.Error())
+			}
+		}
+		if err := store.Scenarios.Add(&Scenario{Name: "a", Definition: "again"}); err == nil {
+			t.Error("adding a duplicate name succeeded")
+		}
+
+		a, err := store.Scenarios.GetByName("a")
+		if err != nil || a.Id == 0 || a.Definition != "definition of a" {
+			t.Fatalf("GetByName(a) = %+v, %v", a, err)
+		}
+		byId, err := store.Scenarios.GetById(a.Id)
+		if err != nil || byId.Name != "a" {
+			t.Errorf("GetById(%d) = %+v, %v", a.Id, byId, err)
+		}
+		missing, err := store.Scenarios.GetByName("missing")
+		if err != nil || missing.Id != 0 {
+			t.Errorf("GetByName(missing) = %+v, %v, want an empty scenario", missing, err)
+		}
+
+		scenarios, err := store.Scenarios.List()
+		if err != nil || len(scenarios) != 2 || scenarios[0].Name != "a" || scenarios[1].Name != "b" {
+			t.Errorf("List() = %+v, %v, want a and b by name", scenarios, err)
+		}
+
+		a.Definition = "updated"
+		a.UpdatedAt = time.Now()
+		err = store.Scenarios.Update(&a)
+		if err != nil {
+			t.Fatalf("update scenario failed, reason: %s", err.Error())
+		}
+		updated, _ := store.Scenarios.GetByName("a")
+		if updated.Definition != "updated" {
+			t.Errorf("definition after Update = %q, want updated", updated.Definition)
+		}
+
+		err = store.Scenarios.DeleteByName("a")
+		if err != nil {
+			t.Fatalf("delete scenario failed, reason: %s", err.Error())
+		}
+		deleted, err := store.Scenarios.GetByName("a")
+		if err != nil || deleted.Id != 0 {
+			t.Errorf("GetByName(a) after delete = %+v, %v", deleted, err)
+		}
+	})
+}
+
+func TestRunStore(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		runs := []*JobStatus{
+			{ScenarioId: 1, Status: "first"},
+			{ScenarioId: 2, Status: "second"},
+			{Status: "ad-hoc"},
+		}
+		for _, run := range runs {
+			err := store.Runs.Add(run)
+			if err != nil {
+				t.Fatalf("add run failed, reason: %s", err.Error())
+			}
+		}
+
+		run, err := store.Runs.GetById(runs[0].Id)
+		if err != nil || run.Status != "first" || run.Version != 0 {
+			t.Fatalf("GetById(%d) = %+v, %v", runs[0].Id, run, err)
+		}
+		missing, err := store.Runs.GetById(runs[2].Id + 100)
+		if err != nil || missing.Id != 0 {
+			t.Errorf("GetById(missing) = %+v, %v, want an empty run", missing, err)
+		}
+
+		stale := run
+		run.Status = "updated"
+		run.UpdatedAt = time.Now()
+		err = store.Runs.UpdateById(&run)
+		if err != nil || run.Version != 1 {
+			t.Fatalf("UpdateById() = %v, version %d, want nil and version 1", err, run.Version)
+		}
+		stale.Status = "lost"
+		err = store.Runs.UpdateById(&stale)
+		if !errors.Is(err, ErrVersionConflict) {
+			t.Errorf("UpdateById() of a stale version = %v, want ErrVersionConflict", err)
+		}
+		run, _ = store.Runs.GetById(run.Id)
+		if run.Status != "updated" || run.Version != 1 {
+			t.Errorf("run after the conflict = %+v, want the first update", run)
+		}
+
+		before, err := store.Runs.ListBefore(time.Now().Add(time.Minute))
+		if err != nil || len(before) != 3 || before[0].Id != runs[0].Id {
+			t.Errorf("ListBefore(future) = %+v, %v, want the 3 runs by id", before, err)
+		}
+		before, err = store.Runs.ListBefore(time.Now().Add(-time.Minute))
+		if err != nil || len(before) != 0 {
+			t.Errorf("ListBefore(past) = %+v, %v, want none", before, err)
+		}
+
+		for i := 0; i < 3; i++ {
+			err = store.Runs.Add(&JobStatus{ScenarioId: 1, Status: "more"})
+			if err != nil {
+				t.Fatalf("add run failed, reason: %s", err.Error())
+			}
+		}
+		exceeded, err := store.Runs.ListByScenarioId(1, 2)
+		if err != nil || len(exceeded) != 2 || exceeded[0].Id <= exceeded[1].Id || exceeded[1].Id != runs[0].Id {
+			t.Errorf("ListByScenarioId(1, 2) = %+v, %v, want the 2 oldest runs from the newest", exceeded, err)
+		}
+		exceeded, err = store.Runs.ListByScenarioId(1, 4)
+		if err != nil || len(exceeded) != 0 {
+			t.Errorf("ListByScenarioId(1, 4) = %+v, %v, want none", exceeded, err)
+		}
+
+		ids, err := store.Runs.ScenarioIds()
+		if err != nil || len(ids) != 2 {
+			t.Errorf("ScenarioIds() = %v, %v, want the 2 stored scenarios", ids, err)
+		}
+
+		err = store.Runs.DeleteById(runs[0].Id)
+		if err != nil {
+			t.Fatalf("delete run failed, reason: %s", err.Error())
+		}
+		deleted, err := store.Runs.GetById(runs[0].Id)
+		if err != nil || deleted.Id != 0 {
+			t.Errorf("GetById() after delete = %+v, %v", deleted, err)
+		}
+	})
+}
+
+func TestEventStore(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		now := time.Now()
+		events := []*JobEvent{
+			{CreatedAt: now.Add(time.Second), RunId: 1, Step: "s", FromStatus: "pending", ToStatus: "running",
+				TargetPods: []string{"p1"}},
+			{CreatedAt: now, RunId: 1, Step: "s", ToStatus: "pending"},
+			{CreatedAt: now, RunId: 2, Step: "s", ToStatus: "pending"},
+		}
+		for _, event := range events {
+			err := store.Events.Add(event)
+			if err != nil {
+				t.Fatalf("add event failed, reason: %s", err.Error())
+			}
+		}
+
+		listed, err := store.Events.ListByRunId(1)
+		if err != nil || len(listed) != 2 {
+			t.Fatalf("ListByRunId(1) = %+v, %v, want 2 events", listed, err)
+		}
+		if listed[0].ToStatus != "pending" || listed[1].ToStatus != "running" {
+			t.Errorf("ListByRunId(1) = %+v, want the events by time", listed)
+		}
+		if len(listed[1].TargetPods) != 1 || listed[1].TargetPods[0] != "p1" {
+			t.Errorf("target pods = %v, want [p1]", listed[1].TargetPods)
+		}
+
+		err = store.Events.DeleteByRunId(1)
+		if err != nil {
+			t.Fatalf("delete events failed, reason: %s", err.Error())
+		}
+		listed, _ = store.Events.ListByRunId(1)
+		kept, _ := store.Events.ListByRunId(2)
+		if len(listed) != 0 || len(kept) != 1 {
+			t.Errorf("after delete run 1 has %d events and run 2 has %d, want 0 and 1", len(listed), len(kept))
+		}
+	})
+}
+
+func TestWebhookStore(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		webhook := &Webhook{Url: "http://localhost/hook", Events: []string{"run.finished"}, Secret: "secret"}
+		err := store.Webhooks.Add(webhook)
+		if err != nil {
+			t.Fatalf("add webhook failed, reason: %s", err.Error())
+		}
+		webhooks, err := store.Webhooks.List()
+		if err != nil || len(webhooks) != 1 || webhooks[0].Secret != "secret" || webhooks[0].Events[0] != "run.finished" {
+			t.Fatalf("List() = %+v, %v", webhooks, err)
+		}
+
+		for _, event := range []string{"run.started", "run.finished"} {
+			err = store.Webhooks.AddDelivery(&WebhookDelivery{WebhookId: webhook.Id, Event: event, Payload: "{}"})
+			if err != nil {
+				t.Fatalf("add delivery failed, reason: %s", err.Error())
+			}
+		}
+		deliveries, err := store.Webhooks.ListDeliveries(webhook.Id)
+		if err != nil || len(deliveries) != 2 || deliveries[0].Event != "run.finished" {
+			t.Fatalf("ListDeliveries() = %+v, %v, want the newest first", deliveries, err)
+		}
+
+		delivery := deliveries[0]
+		delivery.Attempts = 2
+		delivery.StatusCode = 200
+		delivery.Delivered = true
+		delivery.UpdatedAt = time.Now()
+		err = store.Webhooks.UpdateDelivery(&delivery)
+		if err != nil {
+			t.Fatalf("update delivery failed, reason: %s", err.Error())
+		}
+		deliveries, _ = store.Webhooks.ListDeliveries(webhook.Id)
+		if !deliveries[0].Delivered || deliveries[0].Attempts != 2 || deliveries[0].StatusCode != 200 {
+			t.Errorf("delivery after update = %+v", deliveries[0])
+		}
+
+		err = store.Webhooks.DeleteById(webhook.Id)
+		if err != nil {
+			t.Fatalf("delete webhook failed, reason: %s", err.Error())
+		}
+		webhooks, _ = store.Webhooks.List()
+		deliveries, _ = store.Webhooks.ListDeliveries(webhook.Id)
+		if len(webhooks) != 0 || len(deliveries) != 0 {
+			t.Errorf("after delete %d webhooks and %d deliveries are left", len(webhooks), len(deliveries))
+		}
+	})
+}
+
+func TestNotificationStore(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		for _, scenario := range []string{"a", "b"} {
+			err := store.Notifications.Add(&ChatNotification{Scenario: scenario, Url: "http://localhost/chat",
+				Events: []string{"run.finished"}})
+			if err != nil {
+				t.Fatalf("add notification failed, reason: %s", err.Error())
+			}
+		}
+		notifications, err := store.Notifications.ListByScenario("a")
+		if err != nil || len(notifications) != 1 || notifications[0].Scenario != "a" {
+			t.Fatalf("ListByScenario(a) = %+v, %v", notifications, err)
+		}
+
+		err = store.Notifications.DeleteById(notifications[0].Id)
+		if err != nil {
+			t.Fatalf("delete notification failed, reason: %s", err.Error())
+		}
+		all, err := store.Notifications.List()
+		if err != nil || len(all) != 1 || all[0].Scenario != "b" {
+			t.Errorf("List() after delete = %+v, %v, want b only", all, err)
+		}
+	})
+}
+
+// TestMysqlRunStoreSql checks the queries mysql would run, mysql can not run in the tests.
+func TestMysqlRunStoreSql(t *testing.T) {
+	dialector := mysql.New(mysql.Config{DSN: "godzilla@tcp(localhost)/godzilla", SkipInitializeWithVersion: true})
+	conn, err := gorm.Open(dialector, &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatalf("open mysql failed, reason: %s", err.Error())
+	}
+	var sql string
+	err = conn.Callback().Query().After("gorm:query").Register("test:sql", func(db *gorm.DB) {
+		sql = db.Statement.SQL.String()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := &gormRunStore{db: conn}
+	_, err = store.ListByScenarioId(1, 2)
+	if err != nil {
+		t.Fatalf("ListByScenarioId() failed, reason: %s", err.Error())
+	}
+	if !strings.Contains(sql, "LIMIT") {
+		t.Errorf("ListByScenarioId() runs %q, mysql only takes an offset after a limit", sql)
+	}
+}
@@ -0,0 +1,101 @@
+/*
+ *
+ *  * Licensed to the Apache Software Foundation (ASF) under one
+ *  * or more contributor license agreements.  See the NOTICE file
+ *  * distributed with this work for additional information
+ *  * regarding copyright ownership.  The ASF licenses this file
+ *  * to you under the Apache License, Version 2.0 (the
+ *  * "License"); you may not use this file except in compliance
+ *  * with the License.  You may obtain a copy of the License at
+ *  *
+ *  *     http://www.apache.org/licenses/LICENSE-2.0
+ *  *
+ *  * Unless required by applicable law or agreed to in writing, software
+ *  * distributed under the License is distributed on an "AS IS" BASIS,
+ *  * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  * See the License for the specific language governing permissions and
+ *  * limitations under the License.
+ *
+ *
+ */
+
+package db
+
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+// Webhook receives the run lifecycle events it subscribed to, an empty Scenario means all the scenarios.
+type Webhook struct {
+	Id        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	Url       string    `gorm:"size:2048;not null" json:"url"`
+	Events    []string  `gorm:"serializer:json;not null" json:"events"`
+	Scenario  string    `gorm:"size:255" json:"scenario,omitempty"`
+	Secret    string    `gorm:"size:255" json:"-"`
+}
+
+func (*Webhook) TableName() string {
+	return "webhook"
+}
+
+// WebhookDelivery is the history of sending one event to a webhook.
+type WebhookDelivery struct {
+	Id         uint      `gorm:"primarykey" json:"id"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+	WebhookId  uint      `gorm:"not null;index" json:"webhookId"`
+	Event      string    `gorm:"size:64;not null" json:"event"`
+	RunId      uint      `json:"runId"`
+	Payload    string    `gorm:"not null" json:"payload"`
+	Attempts   int       `json:"attempts"`
+	StatusCode int       `json:"statusCode,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Delivered  bool      `json:"delivered"`
+}
+
+func (*WebhookDelivery) TableName() string {
+	return "webhook_delivery"
+}
+
+type gormWebhookStore struct {
+	db *gorm.DB
+}
+
+func (s *gormWebhookStore) Add(webhook *Webhook) error {
+	return s.db.Create(webhook).Error
+}
+
+func (s *gormWebhookStore) List() (webhooks []Webhook, err error) {
+	err = s.db.Order("id").Find(&webhooks).Error
+	return webhooks, err
+}
+
+func (s *gormWebhookStore) DeleteById(id uint) error {
+	err := s.db.Where("webhook_id = ?", id).Delete(&WebhookDelivery{}).Error
+	if err != nil {
+		return err
+	}
+	return s.db.Delete(&Webhook{}, id).Error
+}
+
+func (s *gormWebhookStore) AddDelivery(delivery *WebhookDelivery) error {
+	return s.db.Create(delivery).Error
+}
+
+func (s *gormWebhookStore) UpdateDelivery(delivery *WebhookDelivery) error {
+	return s.db.Model(delivery).Updates(map[string]any{
+		"updated_at":  delivery.UpdatedAt,
+		"attempts":    delivery.Attempts,
+		"status_code": delivery.StatusCode,
+		"error":       delivery.Error,
+		"delivered":   delivery.Delivered,
+	}).Error
+}
+
+func (s *gormWebhookStore) ListDeliveries(webhookId uint) (deliveries []WebhookDelivery, err error) {
+	err = s.db.Where("webhook_id = ?", webhookId).Order("id desc").Find(&deliveries).Error
+	return deliveries, err
+}
@@ -31,6 +31,8 @@ var (
 	JobNamespace  = populateEnv("JOB_NAMESPACE", "test-chaos").(string)
 	LocalDebug    = populateEnv("LOCAL_DEBUG", false).(bool)
 	LogHouse      = populateEnv("LOG_HOUSE", "github-k8s-runner").(string)
+	DbDriver      = populateEnv("GODZILLA_DB_DRIVER", "mysql").(string)
+	DbDsn         = populateEnv("GODZILLA_DB_DSN", "").(string)
 	MysqlUser     = populateEnv("GODZILLA_MYSQL_USER", "root").(string)
 	MysqlPassword = populateEnv("GODZILLA_MYSQL_PASSWORD", "root").(string)
 	MysqlHost     = populateEnv("GODZILLA_MYSQL_HOST", "127.0.0.1").(string)
@@ -38,6 +40,70 @@ var (
 	MysqlDatabase = populateEnv("GODZILLA_MYSQL_DATABASE", "godzilla").(string)
 )
 
+var (
+	LogFormat = populateEnv("LOG_FORMAT", "text").(string)
+	LogLevel  = populateEnv("LOG_LEVEL", "info").(string)
+)
+
+var (
+	StatusDeadLetterFile = populateEnv("STATUS_DEAD_LETTER_FILE", "").(string)
+	// ExternalUrl is the address of godzilla used in the links of the notifications
+	ExternalUrl = populateEnv("GODZILLA_EXTERNAL_URL", "").(string)
+)
+
+var (
+	RunRetentionMaxAge   = populateEnv("RUN_RETENTION_MAX_AGE", "").(string)
+	RunRetentionMaxRuns  = populateEnv("RUN_RETENTION_MAX_RUNS", "0").(string)
+	RunRetentionInterval = populateEnv("RUN_RETENTION_INTERVAL", "1h").(string)
+	RunArchiveDir        = populateEnv("RUN_ARCHIVE_DIR", "").(string)
+)
+
+var (
+	// RunWaitMax caps how long a request waits for a run to finish
+	RunWaitMax = populateEnv("RUN_WAIT_MAX", "30m").(string)
+)
+
+var (
+	OtlpEndpoint    = populateEnv("OTEL_EXPORTER_OTLP_ENDPOINT", "").(string)
+	OtelServiceName = populateEnv("OTEL_SERVICE_NAME", "godzilla").(string)
+)
+
+var (
+	GrafanaUrl          = populateEnv("GRAFANA_URL", "").(string)
+	GrafanaToken        = populateEnv("GRAFANA_TOKEN", "").(string)
+	GrafanaDashboardUid = populateEnv("GRAFANA_DASHBOARD_UID", "").(string)
+)
+
+var (
+	AlertmanagerUrl            = populateEnv("ALERTMANAGER_URL", "").(string)
+	AlertmanagerSeverities     = populateEnv("ALERTMANAGER_SEVERITIES", "critical").(string)
+	AlertmanagerNamespaceLabel = populateEnv("ALERTMANAGER_NAMESPACE_LABEL", "namespace").(string)
+	// AlertmanagerWait makes the runs wait for the alerts to resolve instead of being refused
+	AlertmanagerWait = populateEnv("ALERTMANAGER_WAIT", "").(string)
+	// AlertmanagerSilenceDuration enables the silences of the target namespaces during the runs
+	AlertmanagerSilenceDuration = populateEnv("ALERTMANAGER_SILENCE_DURATION", "").(string)
+)
+
+var (
+	// LogStore is where the logs of the chaos jobs are kept, local or s3, the s3 bucket is LOG_HOUSE
+	LogStore          = populateEnv("LOG_STORE", "local").(string)
+	LogStoreDir       = populateEnv("LOG_STORE_DIR", "logs").(string)
+	S3Endpoint        = populateEnv("S3_ENDPOINT", "s3.amazonaws.com").(string)
+	S3Region          = populateEnv("S3_REGION", "").(string)
+	S3AccessKeyId     = populateEnv("S3_ACCESS_KEY_ID", "").(string)
+	S3SecretAccessKey = populateEnv("S3_SECRET_ACCESS_KEY", "").(string)
+	S3UseSsl          = populateEnv("S3_USE_SSL", "true").(string)
+)
+
+var (
+	// EvidenceEnabled snapshots the target namespace before and after each step, it is opt-in as the
+	// evidence holds the specs and logs of the targets
+	EvidenceEnabled  = populateEnv("EVIDENCE_ENABLED", "false").(string)
+	ArtifactStore    = populateEnv("ARTIFACT_STORE", "local").(string)
+	ArtifactStoreDir = populateEnv("ARTIFACT_STORE_DIR", "artifacts").(string)
+	ArtifactBucket   = populateEnv("ARTIFACT_BUCKET", LogHouse).(string)
+)
+
 func populateEnv(name string, defaultValue any) any {
 	if name == "LOCAL_DEBUG" {
 		if os.Getenv(name) != "" {
@@ -60,4 +126,10 @@ func ParseVars() {
 	logrus.Info("vars for current run")
 	logrus.Infof("LOCAL_DEBUG: %v", LocalDebug)
 	logrus.Infof("LOG_HOUSE %s", LogHouse)
+	logrus.Infof("GODZILLA_DB_DRIVER: %s", DbDriver)
+	logrus.Infof("OTEL_EXPORTER_OTLP_ENDPOINT: %s", OtlpEndpoint)
+	logrus.Infof("GRAFANA_URL: %s", GrafanaUrl)
+	logrus.Infof("ALERTMANAGER_URL: %s", AlertmanagerUrl)
+	logrus.Infof("LOG_STORE: %s", LogStore)
+	logrus.Infof("ARTIFACT_STORE: %s", ArtifactStore)
 }
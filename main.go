@@ -27,19 +27,27 @@ import (
 	"godzilla/core"
 	"godzilla/db"
 	"godzilla/env"
+	"godzilla/storage"
 )
 
+var handler *chaos.Handler
+
 func init() {
 	core.InitLogrus()
 	env.ParseVars()
-	db.Open()
+	core.InitTracing()
+	handler = chaos.NewHandler(db.Open(), storage.OpenLogs(), storage.OpenArtifacts())
 	chaos.InitKubeClient()
-	go chaos.StatusWorker()
+	go handler.SweepTargets()
+	go handler.StatusWorker()
+	go handler.RetentionWorker()
+	go handler.WebhookWorker()
+	go chaos.AnnotationWorker()
 	//kube.ReadyChaosEnv()
 }
 
 func main() {
-	r := core.SetupRouter()
+	r := core.SetupRouter(handler)
 
 	err := r.Run()
 	if err != nil {
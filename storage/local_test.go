@@ -0,0 +1,138 @@
+/*
+ *
+ *  * Licensed to the Apache Software Foundation (ASF) under one
+ *  * or more contributor license agreements.  See the NOTICE file
+ *  * distributed with this work for additional information
+ *  * regarding copyright ownership.  The ASF licenses this file
+ *  * to you under the Apache License, Version 2.0 (the
+ *  * "License"); you may not use this file except in compliance
+ *  * with the License.  You may obtain a copy of the License at
+ *  *
+ *  *     http://www.apache.org/licenses/LICENSE-2.0
+ *  *
+ *  * Unless required by applicable law or agreed to in writing, software
+ *  * distributed under the License is distributed on an "AS IS" BASIS,
+ *  * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  * See the License for the specific language governing permissions and
+ *  * limitations under the License.
+ *
+ *
+ */
+package storage
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func putObjects(t *testing.T, store Store, keys ...string) {
+	t.Helper()
+	for _, key := range keys {
+		err := store.Put(context.Background(), key, strings.NewReader("content of "+key))
+		if err != nil {
+			t.Fatalf("Put(%s) = %v, want nil", key, err)
+		}
+	}
+}
+
+func TestLocalStoreRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	putObjects(t, store, "runs/1/step/pod.log")
+	// a second put replaces the object
+	err = store.Put(ctx, "runs/1/step/pod.log", strings.NewReader("new content"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := store.Get(ctx, "runs/1/step/pod.log")
+	if err != nil {
+		t.Fatalf("Get() = %v, want nil", err)
+	}
+	data, err := io.ReadAll(r)
+	r.Close()
+	if err != nil || string(data) != "new content" {
+		t.Errorf("Get() read %q, %v, want %q", data, err, "new content")
+	}
+	_, err = store.Get(ctx, "runs/2/step/pod.log")
+	if err != ErrNotFound {
+		t.Errorf("Get() of a missing key = %v, want %v", err, ErrNotFound)
+	}
+	// the keys can't escape the directory of the store
+	_, err = store.Get(ctx, "../../etc/passwd")
+	if err != ErrNotFound {
+		t.Errorf("Get() outside the store = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestLocalStoreList(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	putObjects(t, store, "runs/1/b/pod.log", "runs/1/a/pod.log", "runs/10/a/pod.log", "evidence/1/a/pod.yaml")
+
+	tests := []struct {
+		prefix string
+		want   []string
+	}{
+		{"runs/1/", []string{"runs/1/a/pod.log", "runs/1/b/pod.log"}},
+		{"runs/1/a/", []string{"runs/1/a/pod.log"}},
+		{"runs/1", []string{"runs/1/a/pod.log", "runs/1/b/pod.log", "runs/10/a/pod.log"}},
+		{"", []string{"evidence/1/a/pod.yaml", "runs/1/a/pod.log", "runs/1/b/pod.log", "runs/10/a/pod.log"}},
+		{"runs/2/", nil},
+		{"logs/", nil},
+	}
+	for _, tt := range tests {
+		keys, err := store.List(ctx, tt.prefix)
+		if err != nil || !reflect.DeepEqual(keys, tt.want) {
+			t.Errorf("List(%q) = %v, %v, want %v", tt.prefix, keys, err, tt.want)
+		}
+	}
+}
+
+func TestLocalStoreDeletePrefix(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := NewLocalStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	putObjects(t, store, "runs/1/a/pod.log", "runs/1/b/pod.log", "runs/10/a/pod.log")
+
+	err = store.DeletePrefix(ctx, "runs/1/")
+	if err != nil {
+		t.Fatalf("DeletePrefix() = %v, want nil", err)
+	}
+	keys, _ := store.List(ctx, "")
+	if want := []string{"runs/10/a/pod.log"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("List() after DeletePrefix() = %v, want %v", keys, want)
+	}
+	_, err = os.Stat(filepath.Join(dir, "runs", "1"))
+	if !os.IsNotExist(err) {
+		t.Errorf("directory of the deleted run: %v, want it removed", err)
+	}
+
+	err = store.DeletePrefix(ctx, "runs/10/")
+	if err != nil {
+		t.Fatalf("DeletePrefix() = %v, want nil", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 0 {
+		t.Errorf("root of the store = %v, %v, want an empty directory", entries, err)
+	}
+	// deleting a prefix without objects is a no-op
+	err = store.DeletePrefix(ctx, "runs/10/")
+	if err != nil {
+		t.Errorf("DeletePrefix() of a missing prefix = %v, want nil", err)
+	}
+}
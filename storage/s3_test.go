@@ -0,0 +1,180 @@
+/*
+ *
+ *  * Licensed to the Apache Software Foundation (ASF) under one
+ *  * or more contributor license agreements.  See the NOTICE file
+ *  * distributed with this work for additional information
+ *  * regarding copyright ownership.  The ASF licenses this file
+ *  * to you under the Apache License, Version 2.0 (the
+ *  * "License"); you may not use this file except in compliance
+ *  * with the License.  You may obtain a copy of the License at
+ *  *
+ *  *     http://www.apache.org/licenses/LICENSE-2.0
+ *  *
+ *  * Unless required by applicable law or agreed to in writing, software
+ *  * distributed under the License is distributed on an "AS IS" BASIS,
+ *  * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  * See the License for the specific language governing permissions and
+ *  * limitations under the License.
+ *
+ *
+ */
+package storage
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeS3 is a stand-in for a bucket of an S3 service, it serves the calls of s3Store with path-style urls.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	uploads map[string]map[int][]byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	// the path is /<bucket>/<key>
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	query := r.URL.Query()
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		id := strconv.Itoa(len(f.uploads) + 1)
+		f.uploads[id] = make(map[int][]byte)
+		writeXml(w, struct {
+			XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+			Bucket   string
+			Key      string
+			UploadId string
+		}{Bucket: bucket, Key: key, UploadId: id})
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		part, _ := strconv.Atoi(query.Get("partNumber"))
+		data, _ := io.ReadAll(r.Body)
+		f.uploads[query.Get("uploadId")][part] = data
+		w.Header().Set("ETag", fmt.Sprintf(`"%d"`, part))
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		parts := f.uploads[query.Get("uploadId")]
+		var data []byte
+		for i := 1; i <= len(parts); i++ {
+			data = append(data, parts[i]...)
+		}
+		f.objects[key] = data
+		delete(f.uploads, query.Get("uploadId"))
+		writeXml(w, struct {
+			XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+			Bucket  string
+			Key     string
+			ETag    string
+		}{Bucket: bucket, Key: key, ETag: `"object"`})
+	case r.Method == http.MethodGet && key == "":
+		type content struct{ Key string }
+		var contents []content
+		for k := range f.objects {
+			if strings.HasPrefix(k, query.Get("prefix")) {
+				contents = append(contents, content{Key: k})
+			}
+		}
+		sort.Slice(contents, func(i, j int) bool { return contents[i].Key < contents[j].Key })
+		writeXml(w, struct {
+			XMLName  xml.Name `xml:"ListBucketResult"`
+			KeyCount int
+			Contents []content
+		}{KeyCount: len(contents), Contents: contents})
+	case r.Method == http.MethodHead || r.Method == http.MethodGet:
+		data, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("ETag", `"object"`)
+		w.Header().Set("Last-Modified", "Mon, 19 Oct 2026 10:00:00 GMT")
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		if r.Method == http.MethodGet {
+			w.Write(data)
+		}
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+func writeXml(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(v)
+}
+
+func newFakeS3Store(t *testing.T, prefix string) (Store, *fakeS3) {
+	t.Helper()
+	fake := &fakeS3{objects: make(map[string][]byte), uploads: make(map[string]map[int][]byte)}
+	server := httptest.NewTLSServer(fake)
+	t.Cleanup(server.Close)
+	client, err := minio.New(strings.TrimPrefix(server.URL, "https://"), &minio.Options{
+		Creds:     credentials.NewStaticV4("access", "secret", ""),
+		Secure:    true,
+		Region:    "us-east-1",
+		Transport: server.Client().Transport,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &s3Store{client: client, bucket: "godzilla", prefix: prefix}, fake
+}
+
+func TestS3StoreRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	store, fake := newFakeS3Store(t, "archive/")
+	putObjects(t, store, "runs/1/step/pod.log")
+	if _, ok := fake.objects["archive/runs/1/step/pod.log"]; !ok {
+		t.Errorf("Put() stored %v, want the key under the prefix", reflect.ValueOf(fake.objects).MapKeys())
+	}
+
+	r, err := store.Get(ctx, "runs/1/step/pod.log")
+	if err != nil {
+		t.Fatalf("Get() = %v, want nil", err)
+	}
+	data, err := io.ReadAll(r)
+	r.Close()
+	if err != nil || string(data) != "content of runs/1/step/pod.log" {
+		t.Errorf("Get() read %q, %v, want %q", data, err, "content of runs/1/step/pod.log")
+	}
+	_, err = store.Get(ctx, "runs/2/step/pod.log")
+	if err != ErrNotFound {
+		t.Errorf("Get() of a missing key = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestS3StoreListAndDeletePrefix(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newFakeS3Store(t, "archive/")
+	putObjects(t, store, "runs/1/a/pod.log", "runs/1/b/pod.log", "runs/12/a/pod.log")
+
+	keys, err := store.List(ctx, "runs/1/")
+	want := []string{"runs/1/a/pod.log", "runs/1/b/pod.log"}
+	if err != nil || !reflect.DeepEqual(keys, want) {
+		t.Errorf("List() = %v, %v, want %v", keys, err, want)
+	}
+	err = store.DeletePrefix(ctx, "runs/1/")
+	if err != nil {
+		t.Fatalf("DeletePrefix() = %v, want nil", err)
+	}
+	keys, err = store.List(ctx, "runs/")
+	want = []string{"runs/12/a/pod.log"}
+	if err != nil || !reflect.DeepEqual(keys, want) {
+		t.Errorf("List() after DeletePrefix() = %v, %v, want %v", keys, err, want)
+	}
+}
@@ -0,0 +1,115 @@
+/*
+ *
+ *  * Licensed to the Apache Software Foundation (ASF) under one
+ *  * or more contributor license agreements.  See the NOTICE file
+ *  * distributed with this work for additional information
+ *  * regarding copyright ownership.  The ASF licenses this file
+ *  * to you under the Apache License, Version 2.0 (the
+ *  * "License"); you may not use this file except in compliance
+ *  * with the License.  You may obtain a copy of the License at
+ *  *
+ *  *     http://www.apache.org/licenses/LICENSE-2.0
+ *  *
+ *  * Unless required by applicable law or agreed to in writing, software
+ *  * distributed under the License is distributed on an "AS IS" BASIS,
+ *  * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  * See the License for the specific language governing permissions and
+ *  * limitations under the License.
+ *
+ *
+ */
+
+// Package storage keeps the files of the runs, e.g. the logs of the chaos jobs, on the local disk
+// or in an S3-compatible bucket.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"godzilla/env"
+	"io"
+	"strconv"
+)
+
+const (
+	LocalKind = "local"
+	S3Kind    = "s3"
+)
+
+var ErrNotFound = errors.New("object not found")
+
+// Store keeps the objects by keys made of slash separated segments, e.g. logs/12/step/pod/container.log.
+type Store interface {
+	// Put writes the object, r is read until EOF
+	Put(ctx context.Context, key string, r io.Reader) error
+	// Get returns ErrNotFound if there is no object for the key
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// List returns the keys starting with prefix in lexical order
+	List(ctx context.Context, prefix string) ([]string, error)
+	DeletePrefix(ctx context.Context, prefix string) error
+}
+
+// Config selects the store, Dir is the root directory of the local store and Bucket the bucket of
+// the S3 store, the keys of the S3 store are prefixed with Prefix.
+type Config struct {
+	Kind   string
+	Dir    string
+	Bucket string
+	Prefix string
+	S3     S3Config
+}
+
+func Open(config Config) (Store, error) {
+	switch config.Kind {
+	case LocalKind, "":
+		return NewLocalStore(config.Dir)
+	case S3Kind:
+		return NewS3Store(config.S3, config.Bucket, config.Prefix)
+	default:
+		return nil, fmt.Errorf("unsupported store %s", config.Kind)
+	}
+}
+
+func s3ConfigFromEnv() S3Config {
+	useSsl, err := strconv.ParseBool(env.S3UseSsl)
+	if err != nil {
+		logrus.Fatalf("parse S3_USE_SSL failed, reason: %s", err.Error())
+	}
+	return S3Config{
+		Endpoint:        env.S3Endpoint,
+		Region:          env.S3Region,
+		AccessKeyId:     env.S3AccessKeyId,
+		SecretAccessKey: env.S3SecretAccessKey,
+		UseSsl:          useSsl,
+	}
+}
+
+// OpenLogs opens the store of LOG_STORE for the logs of the chaos jobs.
+func OpenLogs() Store {
+	store, err := Open(Config{
+		Kind:   env.LogStore,
+		Dir:    env.LogStoreDir,
+		Bucket: env.LogHouse,
+		S3:     s3ConfigFromEnv(),
+	})
+	if err != nil {
+		logrus.Fatalf("open log store %s failed, reason: %s", env.LogStore, err.Error())
+	}
+	return store
+}
+
+// OpenArtifacts opens the store of ARTIFACT_STORE for the evidence of the runs.
+func OpenArtifacts() Store {
+	store, err := Open(Config{
+		Kind:   env.ArtifactStore,
+		Dir:    env.ArtifactStoreDir,
+		Bucket: env.ArtifactBucket,
+		S3:     s3ConfigFromEnv(),
+	})
+	if err != nil {
+		logrus.Fatalf("open artifact store %s failed, reason: %s", env.ArtifactStore, err.Error())
+	}
+	return store
+}
@@ -0,0 +1,53 @@
+/*
+ *
+ *  * Licensed to the Apache Software Foundation (ASF) under one
+ *  * or more contributor license agreements.  See the NOTICE file
+ *  * distributed with this work for additional information
+ *  * regarding copyright ownership.  The ASF licenses this file
+ *  * to you under the Apache License, Version 2.0 (the
+ *  * "License"); you may not use this file except in compliance
+ *  * with the License.  You may obtain a copy of the License at
+ *  *
+ *  *     http://www.apache.org/licenses/LICENSE-2.0
+ *  *
+ *  * Unless required by applicable law or agreed to in writing, software
+ *  * distributed under the License is distributed on an "AS IS" BASIS,
+ *  * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  * See the License for the specific language governing permissions and
+ *  * limitations under the License.
+ *
+ *
+ */
+package types
+
+import "encoding/json"
+
+// DryRun is what a run would do, nothing of it is created.
+type DryRun struct {
+	Scenario string `json:"scenario"`
+	// Refused is why the run would be refused, e.g. alerts firing in the target namespaces
+	Refused string         `json:"refused,omitempty"`
+	Stages  [][]DryRunStep `json:"stages"`
+}
+
+// DryRunStep is a step with its resolved config and targets, and the chaos jobs it would create.
+type DryRunStep struct {
+	Name               string            `json:"name"`
+	Type               string            `json:"type"`
+	Image              string            `json:"image"`
+	ServiceAccountName string            `json:"serviceAccountName"`
+	Config             map[string]string `json:"config"`
+	// Targets are the pods picked by godzilla, TARGET_PODS or the ones of APP_LABEL for pod-io-stress
+	Targets []DryRunTarget `json:"targets,omitempty"`
+	// Candidates are the ready pods of APP_LABEL the pod-delete experiment picks its victims from
+	Candidates []string `json:"candidates,omitempty"`
+	// Jobs are the batch/v1 Job manifests, they decode into the Job of k8s.io/api/batch/v1
+	Jobs []json.RawMessage `json:"jobs"`
+	// Error is why the targets could not be resolved, the step would fail with it
+	Error string `json:"error,omitempty"`
+}
+
+type DryRunTarget struct {
+	Pod  string `json:"pod"`
+	Node string `json:"node,omitempty"`
+}
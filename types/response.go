@@ -0,0 +1,46 @@
+/*
+ *
+ *  * Licensed to the Apache Software Foundation (ASF) under one
+ *  * or more contributor license agreements.  See the NOTICE file
+ *  * distributed with this work for additional information
+ *  * regarding copyright ownership.  The ASF licenses this file
+ *  * to you under the Apache License, Version 2.0 (the
+ *  * "License"); you may not use this file except in compliance
+ *  * with the License.  You may obtain a copy of the License at
+ *  *
+ *  *     http://www.apache.org/licenses/LICENSE-2.0
+ *  *
+ *  * Unless required by applicable law or agreed to in writing, software
+ *  * distributed under the License is distributed on an "AS IS" BASIS,
+ *  * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  * See the License for the specific language governing permissions and
+ *  * limitations under the License.
+ *
+ *
+ */
+package types
+
+type ResponseStatus string
+
+const (
+	ErrorStatus  ResponseStatus = "error"
+	NormalStatus ResponseStatus = "normal"
+)
+
+type Response struct {
+	Status  ResponseStatus `json:"status"`
+	Message string         `json:"message"`
+	Body    any            `json:"body,omitempty"`
+	// Code, Cause and Details are only set on errors
+	Code    string       `json:"code,omitempty"`
+	Cause   string       `json:"cause,omitempty"`
+	Details []FieldError `json:"details,omitempty"`
+}
+
+// FieldError points at the part of the request which is not valid, Field is the json name of the
+// field or config.KEY for the config of a step.
+type FieldError struct {
+	Step    string `json:"step,omitempty"`
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
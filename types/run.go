@@ -0,0 +1,122 @@
+/*
+ *
+ *  * Licensed to the Apache Software Foundation (ASF) under one
+ *  * or more contributor license agreements.  See the NOTICE file
+ *  * distributed with this work for additional information
+ *  * regarding copyright ownership.  The ASF licenses this file
+ *  * to you under the Apache License, Version 2.0 (the
+ *  * "License"); you may not use this file except in compliance
+ *  * with the License.  You may obtain a copy of the License at
+ *  *
+ *  *     http://www.apache.org/licenses/LICENSE-2.0
+ *  *
+ *  * Unless required by applicable law or agreed to in writing, software
+ *  * distributed under the License is distributed on an "AS IS" BASIS,
+ *  * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  * See the License for the specific language governing permissions and
+ *  * limitations under the License.
+ *
+ *
+ */
+// Package types holds the request and response types of the api. It only depends on the standard
+// library, so that the client and godzillactl do not pull in the server.
+package types
+
+import "time"
+
+// JobStatus is the status of a step
+//
+// pending -> running -> success -> failed
+//
+//				     \-> failed
+//	                 \-> unknown
+//	                 \-> aborted
+type JobStatus string
+
+const (
+	PendingStatus JobStatus = "pending"
+	RunningStatus JobStatus = "running"
+	SuccessStatus JobStatus = "success"
+	FailedStatus  JobStatus = "failed"
+	UnknownStatus JobStatus = "unknown"
+	AbortedStatus JobStatus = "aborted"
+)
+
+// ChaosJob is a step of a scenario, the stages of a scenario are lists of parallel steps.
+type ChaosJob struct {
+	Name               string            `yaml:"name" json:"name" binding:"required"`
+	Type               string            `yaml:"type" json:"type" binding:"required"`
+	Config             map[string]string `yaml:"config" json:"config"`
+	Image              string            `yaml:"image" json:"image"`
+	ServiceAccountName string            `yaml:"serviceAccountName" json:"serviceAccountName"`
+	Status             JobStatus         `yaml:"status" json:"status"`
+	FailedReason       string            `yaml:"failedReason" json:"failedReason"`
+	TargetPods         []string          `yaml:"targetPods,omitempty" json:"targetPods,omitempty"`
+	JobNames           []string          `yaml:"jobNames,omitempty" json:"jobNames,omitempty"`
+	UpdatedAt          time.Time         `yaml:"updatedAt,omitempty" json:"updatedAt,omitempty"`
+}
+
+type ChaosBody struct {
+	Scenario         string            `json:"scenario" binding:"required"`
+	OverriddenConfig map[string]string `json:"overriddenConfig,omitempty"`
+	// TriggeredBy defaults to the X-Triggered-By header
+	TriggeredBy string `json:"triggeredBy,omitempty"`
+}
+
+type ScenarioBody struct {
+	Name string `json:"name" binding:"required"`
+	// Definition is the yaml of the stages, a list of parallel steps each
+	Definition string `json:"definition" binding:"required"`
+}
+
+// Scenario is a stored scenario.
+type Scenario struct {
+	Id         uint      `json:"id"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+	Name       string    `json:"name"`
+	Definition string    `json:"definition"`
+}
+
+// RunResult is the body of the responses waiting for a run, Verdict is only set once it is finished.
+type RunResult struct {
+	RunId    uint         `json:"runId"`
+	Finished bool         `json:"finished"`
+	Verdict  string       `json:"verdict,omitempty"`
+	Steps    [][]ChaosJob `json:"steps"`
+}
+
+// JobEvent is a status transition of a step of a run.
+type JobEvent struct {
+	Id         uint      `json:"id"`
+	CreatedAt  time.Time `json:"createdAt"`
+	RunId      uint      `json:"runId"`
+	Step       string    `json:"step"`
+	FromStatus string    `json:"fromStatus"`
+	ToStatus   string    `json:"toStatus"`
+	Reason     string    `json:"reason,omitempty"`
+	TargetPods []string  `json:"targetPods,omitempty"`
+	JobNames   []string  `json:"jobNames,omitempty"`
+}
+
+// RunEnd is the data of the last event of the streams.
+type RunEnd struct {
+	RunId   uint   `json:"runId"`
+	Verdict string `json:"verdict"`
+}
+
+// LogLine is one line of the output of a chaos job pod.
+type LogLine struct {
+	Step      string `json:"step"`
+	Pod       string `json:"pod"`
+	Container string `json:"container"`
+	Time      string `json:"time"`
+	Line      string `json:"line"`
+}
+
+// the formats of the reports of the runs
+const (
+	HtmlFormat     = "html"
+	MarkdownFormat = "markdown"
+	JunitFormat    = "junit"
+)